@@ -1,15 +1,36 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
+	"maps"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,15 +49,17 @@ var (
 )
 
 // TerraformJob tracks the state of an async terraform plan generation.
+// Redactions counts the secrets masked in Output per secret pattern.
 type TerraformJob struct {
-	ID        string    `json:"id"`
-	Status    string    `json:"status"`
-	Phase     string    `json:"phase"`
-	Output    string    `json:"output"`
-	PlanPath  string    `json:"plan_path"`
-	Error     string    `json:"error"`
-	StartedAt time.Time `json:"started_at"`
-	DoneAt    time.Time `json:"done_at,omitempty"`
+	ID         string         `json:"id"`
+	Status     string         `json:"status"`
+	Phase      string         `json:"phase"`
+	Output     string         `json:"output"`
+	Redactions map[string]int `json:"redactions,omitempty"`
+	PlanPath   string         `json:"plan_path"`
+	Error      string         `json:"error"`
+	StartedAt  time.Time      `json:"started_at"`
+	DoneAt     time.Time      `json:"done_at,omitempty"`
 }
 
 func main() {
@@ -49,15 +72,61 @@ func main() {
 	mux.HandleFunc("/api/scan", corsMiddleware(handleScan))
 	mux.HandleFunc("/api/health", corsMiddleware(handleHealth))
 	mux.HandleFunc("/api/version", corsMiddleware(handleVersion))
+	mux.HandleFunc("/api/scans", corsMiddleware(handleScanList))
+	mux.HandleFunc("/api/scans/{id}", corsMiddleware(handleScanGet))
+	mux.HandleFunc("/api/scans/{id}/export", corsMiddleware(handleScanExport))
+	mux.HandleFunc("/api/export", corsMiddleware(handleExport))
+	mux.HandleFunc("/api/reports/compliance", corsMiddleware(handleComplianceReport))
+	mux.HandleFunc("/api/posture/trend", corsMiddleware(handlePostureTrend))
+	mux.HandleFunc("/api/scans/{id}/evidence", corsMiddleware(handleEvidenceBundle))
+	mux.HandleFunc("/api/evidence/verify", corsMiddleware(handleEvidenceVerify))
+	mux.HandleFunc("/api/webhooks", corsMiddleware(handleWebhooks))
+	mux.HandleFunc("/api/webhooks/deliveries", corsMiddleware(handleWebhookDeliveries))
+	mux.HandleFunc("/api/webhooks/{id}", corsMiddleware(handleWebhook))
+	mux.HandleFunc("/api/webhooks/{id}/test", corsMiddleware(handleWebhookTest))
+	mux.HandleFunc("/api/notifications", corsMiddleware(handleNotifications))
+	mux.HandleFunc("/api/notifications/preview", corsMiddleware(handleNotificationPreview))
+	mux.HandleFunc("/api/notifications/{id}", corsMiddleware(handleNotification))
+	mux.HandleFunc("/api/notifications/{id}/test", corsMiddleware(handleNotificationTest))
+	mux.HandleFunc("/api/frameworks", corsMiddleware(handleFrameworks))
+	mux.HandleFunc("/api/frameworks/{id}", corsMiddleware(handleFramework))
+	mux.HandleFunc("/api/frameworks/{id}/score", corsMiddleware(handleFrameworkScore))
+	mux.HandleFunc("/api/severity-overrides", corsMiddleware(handleSeverityOverrides))
+	mux.HandleFunc("/api/severity-overrides/{policy_id}", corsMiddleware(handleSeverityOverride))
+	mux.HandleFunc("/api/waivers", corsMiddleware(handleWaivers))
+	mux.HandleFunc("/api/waivers/{id}", corsMiddleware(handleWaiver))
+	mux.HandleFunc("/api/drift-rules", corsMiddleware(handleDriftRules))
+	mux.HandleFunc("/api/drift-rules/{id}", corsMiddleware(handleDriftRule))
+	mux.HandleFunc("/api/baselines", corsMiddleware(handleBaselines))
+	mux.HandleFunc("/api/baselines/{id}", corsMiddleware(handleBaseline))
+	mux.HandleFunc("/api/remediation", corsMiddleware(handleRemediation))
+	mux.HandleFunc("/api/tickets", corsMiddleware(handleTickets))
+	mux.HandleFunc("/api/tickets/config", corsMiddleware(handleTicketConfig))
+	mux.HandleFunc("/api/tickets/sync", corsMiddleware(handleTicketSync))
+	mux.HandleFunc("/api/policies", corsMiddleware(handlePolicyCatalog))
+	mux.HandleFunc("/api/policies/custom", corsMiddleware(handleCustomPolicies))
+	mux.HandleFunc("/api/policies/custom/{team}/{name}", corsMiddleware(handleCustomPolicy))
+	mux.HandleFunc("/api/policies/test", corsMiddleware(handlePolicyTest))
 	mux.HandleFunc("/api/config", corsMiddleware(handleConfig))
 	mux.HandleFunc("/api/files/plan", corsMiddleware(handlePlanFile))
+	mux.HandleFunc("/api/files/plan/hcl", corsMiddleware(handlePlanHCL))
+	mux.HandleFunc("/api/files/plan/summary", corsMiddleware(handlePlanSummary))
+	mux.HandleFunc("/api/files/plan/diff", corsMiddleware(handlePlanDiff))
 	mux.HandleFunc("/api/files/list", corsMiddleware(handleFileList))
 	mux.HandleFunc("/api/files/upload", corsMiddleware(handleFileUpload))
 	mux.HandleFunc("/api/files/generate-plan", corsMiddleware(handleGeneratePlan))
+	mux.HandleFunc("/api/drafts", corsMiddleware(handleDrafts))
+	mux.HandleFunc("/api/drafts/{id}", corsMiddleware(handleDraft))
+	mux.HandleFunc("/api/drafts/{id}/resources", corsMiddleware(handleDraftResources))
+	mux.HandleFunc("/api/drafts/{id}/resources/{address}", corsMiddleware(handleDraftResource))
+	mux.HandleFunc("/api/drafts/{id}/validate", corsMiddleware(handleDraftValidate))
+	mux.HandleFunc("/api/drafts/{id}/save", corsMiddleware(handleDraftSave))
 	mux.HandleFunc("/api/terraform/status", corsMiddleware(handleTerraformStatus))
 	mux.HandleFunc("/api/terraform/upload", corsMiddleware(handleTerraformUpload))
 	mux.HandleFunc("/api/terraform/plan", corsMiddleware(handleTerraformPlan))
 	mux.HandleFunc("/api/terraform/job", corsMiddleware(handleTerraformJob))
+	mux.HandleFunc("/api/terraform/fix", corsMiddleware(handleTerraformFix))
+	mux.HandleFunc("/api/redactions", corsMiddleware(handleRedactions))
 
 	// Periodically clean up completed terraform jobs older than 1 hour
 	go func() {
@@ -75,6 +144,22 @@ func main() {
 		}
 	}()
 
+	// Record the posture of scans stored before the posture log existed
+	go func() {
+		if err := backfillPosture(); err != nil {
+			log.Printf("Failed to backfill posture: %v", err)
+		}
+	}()
+
+	// Notify once about each waiver that has expired
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			notifyExpiredWaivers()
+		}
+	}()
+
 	log.Printf("Cloudrift API server starting on :%s", port)
 	if err := http.ListenAndServe(":"+port, mux); err != nil {
 		log.Fatal(err)
@@ -84,8 +169,9 @@ func main() {
 func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Scan-ID")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -204,6 +290,28 @@ func handleScan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The catalog is loaded once per scan: custom frameworks are scored and
+	// the evaluated policies recorded from the same set.
+	rec := newStoredScan(req)
+	var catalog []PolicyCatalogEntry
+	if !req.SkipPolicies {
+		var catalogErr error
+		catalog, catalogErr = policyCatalog()
+		rec.Inputs.PolicyIDsComplete = catalogErr == nil
+	}
+	jsonStr = processScanResult(jsonStr, rec.Inputs, catalog)
+	rec.Result = json.RawMessage(jsonStr)
+
+	// Keep a copy for later export; a storage failure should not fail the scan
+	if err := saveScan(rec); err != nil {
+		log.Printf("Failed to store scan result: %v", err)
+	} else {
+		w.Header().Set("X-Scan-ID", rec.ID)
+		go notifyScanCompleted(rec)
+		go syncTickets(rec)
+		go recordPosture(rec)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprint(w, jsonStr)
 }
@@ -218,8 +326,7 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleVersion(w http.ResponseWriter, r *http.Request) {
-	cmd := exec.Command(cliPath(), "--version")
-	output, err := cmd.Output()
+	version, err := cliVersion()
 	if err != nil {
 		jsonError(w, "CLI not available", http.StatusServiceUnavailable)
 		return
@@ -227,8 +334,627 @@ func handleVersion(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"version": strings.TrimSpace(string(output)),
+		"version": version,
+	})
+}
+
+func cliVersion() (string, error) {
+	output, err := exec.Command(cliPath(), "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// toolCacheEntry is a value derived from an external binary, valid while
+// the binary's modification time is unchanged.
+type toolCacheEntry struct {
+	modTime time.Time
+	value   interface{}
+}
+
+var (
+	toolCacheMu sync.Mutex
+	toolCache   = map[string]toolCacheEntry{}
+)
+
+// cachedToolValue returns what fn derives from binary bin, such as its
+// version, running fn again only when the binary is replaced. Errors are
+// not cached.
+func cachedToolValue(bin, key string, fn func() (interface{}, error)) (interface{}, error) {
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := path + "\x00" + key
+	toolCacheMu.Lock()
+	entry, ok := toolCache[cacheKey]
+	toolCacheMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) {
+		return entry.value, nil
+	}
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	toolCacheMu.Lock()
+	toolCache[cacheKey] = toolCacheEntry{modTime: info.ModTime(), value: value}
+	toolCacheMu.Unlock()
+	return value, nil
+}
+
+// ---------------------------------------------------------------------------
+// Scan result model and history
+// ---------------------------------------------------------------------------
+
+// ScanResult mirrors the CLI's `--format=json` output (output.ScanResult).
+type ScanResult struct {
+	Service        string         `json:"service"`
+	AccountID      string         `json:"account_id"`
+	Region         string         `json:"region"`
+	TotalResources int            `json:"total_resources"`
+	DriftCount     int            `json:"drift_count"`
+	Drifts         []DriftInfo    `json:"drifts"`
+	Baselined      []DriftInfo    `json:"baselined_drifts,omitempty"`
+	Ignored        []IgnoredDrift `json:"ignored_drifts,omitempty"`
+	PolicyResult   *PolicyOutput  `json:"policy_result,omitempty"`
+	ScanDurationMs int64          `json:"scan_duration_ms"`
+	Timestamp      string         `json:"timestamp"`
+}
+
+// DriftInfo is the drift detected for a single resource.
+// Each diffs entry is normally an [expected, actual] pair.
+type DriftInfo struct {
+	ResourceID      string                 `json:"resource_id"`
+	ResourceType    string                 `json:"resource_type"`
+	ResourceName    string                 `json:"resource_name"`
+	Missing         bool                   `json:"missing"`
+	Diffs           map[string]interface{} `json:"diffs"`
+	ExtraAttributes map[string]interface{} `json:"extra_attributes"`
+	Severity        string                 `json:"severity"`
+	Baselines       map[string]string      `json:"baselines,omitempty"`
+}
+
+// PolicyOutput holds the OPA policy evaluation results of a scan.
+type PolicyOutput struct {
+	Violations []PolicyViolation `json:"violations"`
+	Warnings   []PolicyViolation `json:"warnings"`
+	Waived     []PolicyViolation `json:"waived,omitempty"`
+	Passed     int               `json:"passed"`
+	Failed     int               `json:"failed"`
+	Compliance *Compliance       `json:"compliance,omitempty"`
+}
+
+// PolicyViolation is a single policy violation or warning.
+type PolicyViolation struct {
+	PolicyID         string   `json:"policy_id"`
+	PolicyName       string   `json:"policy_name"`
+	Message          string   `json:"message"`
+	Severity         string   `json:"severity"`
+	ResourceType     string   `json:"resource_type"`
+	ResourceAddress  string   `json:"resource_address"`
+	Remediation      string   `json:"remediation"`
+	Category         string   `json:"category"`
+	Frameworks       []string `json:"frameworks"`
+	OriginalSeverity string   `json:"original_severity,omitempty"`
+	Waived           bool     `json:"waived,omitempty"`
+	WaiverID         string   `json:"waiver_id,omitempty"`
+}
+
+// Compliance is the CLI-computed compliance scoring.
+type Compliance struct {
+	OverallPercentage float64                    `json:"overall_percentage"`
+	TotalPolicies     int                        `json:"total_policies"`
+	PassingPolicies   int                        `json:"passing_policies"`
+	FailingPolicies   int                        `json:"failing_policies"`
+	Categories        map[string]ComplianceEntry `json:"categories"`
+	Frameworks        map[string]ComplianceEntry `json:"frameworks"`
+	CustomFrameworks  map[string]FrameworkScore  `json:"custom_frameworks,omitempty"`
+}
+
+// ComplianceEntry is the score of a single category or framework.
+type ComplianceEntry struct {
+	Percentage float64 `json:"percentage"`
+	Passed     int     `json:"passed"`
+	Failed     int     `json:"failed"`
+	Total      int     `json:"total"`
+}
+
+// Address is the Terraform address of the drifted resource. The CLI reports
+// resource_name as the full address, such as aws_s3_bucket.app_data; a bare
+// name gets the type prepended.
+func (d DriftInfo) Address() string {
+	if strings.Contains(d.ResourceName, ".") || d.ResourceType == "" {
+		return d.ResourceName
+	}
+	return d.ResourceType + "." + d.ResourceName
+}
+
+// Name is the resource name within its module, without the type.
+func (d DriftInfo) Name() string {
+	return resourceName(d.ResourceType, d.ResourceName)
+}
+
+// resourceName strips the module path and type from an address of a
+// resource of resourceType. Other addresses are returned unchanged.
+func resourceName(resourceType, address string) string {
+	if i := strings.LastIndex(address, resourceType+"."); i >= 0 && resourceType != "" {
+		return address[i+len(resourceType)+1:]
+	}
+	return address
+}
+
+// diffPair splits a diffs entry into its expected and actual values.
+func diffPair(v interface{}) (expected, actual interface{}) {
+	if pair, ok := v.([]interface{}); ok {
+		if len(pair) > 0 {
+			expected = pair[0]
+		}
+		if len(pair) > 1 {
+			actual = pair[1]
+		}
+		return expected, actual
+	}
+	return v, nil
+}
+
+// StoredScan is a scan result persisted by the server after /api/scan.
+type StoredScan struct {
+	ID         string          `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Service    string          `json:"service"`
+	ConfigPath string          `json:"config_path"`
+	PolicyDir  string          `json:"policy_dir,omitempty"`
+	Inputs     *ScanInputs     `json:"inputs,omitempty"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// ScanInputs records what a scan ran with. Copies of the config, plan and
+// custom policies are kept next to the scan record so evidence bundles
+// contain the exact files used, even if they are edited afterwards.
+type ScanInputs struct {
+	CLIVersion        string   `json:"cli_version"`
+	TerraformVersion  string   `json:"terraform_version,omitempty"`
+	PlanPath          string   `json:"plan_path,omitempty"`
+	Files             []string `json:"files"`
+	PolicyIDs         []string `json:"policy_ids,omitempty"`
+	PolicyIDsComplete bool     `json:"policy_ids_complete,omitempty"`
+}
+
+var scanStoreMu sync.Mutex
+
+// dataDir is where the server keeps its own state (scan history etc.).
+func dataDir() string {
+	if d := os.Getenv("CLOUDRIFT_DATA_DIR"); d != "" {
+		return d
+	}
+	return filepath.Join(workDir(), "data")
+}
+
+var dataFileMu sync.Mutex
+
+// loadDataFile reads a JSON state file from the data directory into v.
+// A missing file leaves v untouched.
+func loadDataFile(name string, v interface{}) error {
+	dataFileMu.Lock()
+	defer dataFileMu.Unlock()
+	data, err := os.ReadFile(filepath.Join(dataDir(), name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveDataFile writes v as a JSON state file in the data directory.
+func saveDataFile(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	dataFileMu.Lock()
+	defer dataFileMu.Unlock()
+	path := filepath.Join(dataDir(), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// dataStore is a JSON state file holding a value of type V. Handlers change
+// it through update, which holds the store lock from load to save.
+type dataStore[V any] struct {
+	file  string
+	name  string // plural, for error messages
+	empty func() V
+	mu    sync.Mutex
+}
+
+// load reads the store, or returns its empty value if the file is missing.
+func (s *dataStore[V]) load() (V, error) {
+	v := s.empty()
+	err := loadDataFile(s.file, &v)
+	return v, err
+}
+
+func (s *dataStore[V]) save(v V) error {
+	return saveDataFile(s.file, v)
+}
+
+// update loads the store and calls fn with its value, holding the store
+// lock. fn returns the response to send, its status and whether to save
+// the value first. A nil response means fn has already written an error.
+func (s *dataStore[V]) update(w http.ResponseWriter, fn func(v *V) (interface{}, int, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.load()
+	if err != nil {
+		jsonError(w, "Failed to load "+s.name+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, status, save := fn(&v)
+	if resp == nil {
+		return
+	}
+	if save {
+		if err := s.save(v); err != nil {
+			jsonError(w, "Failed to save "+s.name+": "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// recordStore is a dataStore holding a list of records with unique IDs.
+type recordStore[T any] struct {
+	dataStore[[]T]
+	record string // singular and capitalized, for error messages
+	id     func(T) string
+}
+
+func newRecordStore[T any](file, record, name string, id func(T) string) *recordStore[T] {
+	return &recordStore[T]{
+		dataStore: dataStore[[]T]{file: file, name: name, empty: func() []T { return []T{} }},
+		record:    record,
+		id:        id,
+	}
+}
+
+// add appends rec and answers 201 with resp, or 409 if its ID is taken.
+func (s *recordStore[T]) add(w http.ResponseWriter, rec T, resp interface{}) {
+	s.update(w, func(records *[]T) (interface{}, int, bool) {
+		if slices.ContainsFunc(*records, func(o T) bool { return s.id(o) == s.id(rec) }) {
+			jsonError(w, s.record+" already exists: "+s.id(rec), http.StatusConflict)
+			return nil, 0, false
+		}
+		*records = append(*records, rec)
+		return resp, http.StatusCreated, true
+	})
+}
+
+// withRecord loads the records and calls fn with the one named by the {id}
+// path value, holding the store lock. fn returns the records to save, or
+// nil to leave them unchanged, and the response to send. A nil response
+// means fn has already written an error.
+func (s *recordStore[T]) withRecord(w http.ResponseWriter, r *http.Request, fn func(records []T, idx int) ([]T, interface{}, int)) {
+	id := r.PathValue("id")
+	s.update(w, func(records *[]T) (interface{}, int, bool) {
+		idx := slices.IndexFunc(*records, func(rec T) bool { return s.id(rec) == id })
+		if idx < 0 {
+			jsonError(w, s.record+" not found: "+id, http.StatusNotFound)
+			return nil, 0, false
+		}
+		updated, resp, status := fn(*records, idx)
+		if updated != nil {
+			*records = updated
+		}
+		return resp, status, updated != nil
+	})
+}
+
+// serveRecord handles GET, PUT and DELETE of the record named by the {id}
+// path value. view renders a record for GET. put checks a PUT body decoded
+// over the stored record and returns the response; a nil put rejects PUT.
+func (s *recordStore[T]) serveRecord(w http.ResponseWriter, r *http.Request, view func(T) interface{}, put func(old T, updated *T) (interface{}, error)) {
+	s.withRecord(w, r, func(records []T, idx int) ([]T, interface{}, int) {
+		switch {
+		case r.Method == http.MethodGet:
+			return nil, view(records[idx]), http.StatusOK
+		case r.Method == http.MethodPut && put != nil:
+			updated := records[idx]
+			if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+				jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return nil, nil, 0
+			}
+			resp, err := put(records[idx], &updated)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return nil, nil, 0
+			}
+			records[idx] = updated
+			return records, resp, http.StatusOK
+		case r.Method == http.MethodDelete:
+			return slices.Delete(records, idx, idx+1), map[string]string{"status": "ok"}, http.StatusOK
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return nil, nil, 0
+		}
+	})
+}
+
+func scanFilePath(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid scan id: %s", id)
+	}
+	return filepath.Join(dataDir(), "scans", id+".json"), nil
+}
+
+// scanSnapshotDir holds copies of the files a scan was run with.
+func scanSnapshotDir(id string) (string, error) {
+	path, err := scanFilePath(id)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(path, ".json"), nil
+}
+
+// newStoredScan starts the record of a scan and snapshots its inputs.
+func newStoredScan(req scanRequest) *StoredScan {
+	rec := &StoredScan{
+		ID:         fmt.Sprintf("scan-%d", time.Now().UnixNano()),
+		CreatedAt:  time.Now().UTC(),
+		Service:    strings.ToLower(req.Service),
+		ConfigPath: req.ConfigPath,
+		PolicyDir:  req.PolicyDir,
+	}
+	snapshotDir, _ := scanSnapshotDir(rec.ID)
+	rec.Inputs = snapshotScanInputs(req, snapshotDir)
+	return rec
+}
+
+func saveScan(rec *StoredScan) error {
+	path, err := scanFilePath(rec.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	scanStoreMu.Lock()
+	defer scanStoreMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func loadScan(id string) (*StoredScan, error) {
+	path, err := scanFilePath(id)
+	if err != nil {
+		return nil, err
+	}
+	scanStoreMu.Lock()
+	data, err := os.ReadFile(path)
+	scanStoreMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var rec StoredScan
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// listScans returns all stored scans, newest first.
+func listScans() ([]*StoredScan, error) {
+	scanStoreMu.Lock()
+	entries, err := os.ReadDir(filepath.Join(dataDir(), "scans"))
+	scanStoreMu.Unlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*StoredScan{}, nil
+		}
+		return nil, err
+	}
+	scans := []*StoredScan{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		rec, err := loadScan(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		scans = append(scans, rec)
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].CreatedAt.After(scans[j].CreatedAt)
 	})
+	return scans, nil
+}
+
+// inputPath resolves a path the way the CLI sees it (relative to the work dir).
+func inputPath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(workDir(), p)
+}
+
+// configPlanPath extracts plan_path from a cloudrift config file.
+func configPlanPath(configData []byte) string {
+	for _, line := range strings.Split(string(configData), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "plan_path:") {
+			value := strings.TrimSpace(strings.TrimPrefix(trimmed, "plan_path:"))
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// snapshotScanInputs copies the config, plan and custom policies a scan used
+// into dir and records tool versions and the IDs of the custom policies.
+// Missing inputs are skipped. The plan copy is redacted like stored plans,
+// since evidence bundles serve it. Tool versions are cached per binary, so
+// scans do not run the tools just to ask for their version.
+func snapshotScanInputs(req scanRequest, dir string) *ScanInputs {
+	inputs := &ScanInputs{Files: []string{}}
+	if v, err := cachedToolValue(cliPath(), "version", func() (interface{}, error) { return cliVersion() }); err == nil {
+		inputs.CLIVersion = v.(string)
+	}
+	if v, err := cachedToolValue(terraformPath(), "version", func() (interface{}, error) { return terraformVersion() }); err == nil {
+		inputs.TerraformVersion = v.(string)
+	}
+
+	copyInput := func(src, rel string, redact bool) {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return
+		}
+		if redact {
+			redacted, paths, err := redactPlanJSON(data)
+			if err != nil {
+				return
+			}
+			if len(paths) > 0 {
+				data = redacted
+				recordRedaction(RedactionRecord{Source: "plan", Target: filepath.ToSlash(filepath.Join(filepath.Base(dir), rel)), Action: "snapshot", Paths: paths})
+			}
+		}
+		dst := filepath.Join(dir, rel)
+		if os.MkdirAll(filepath.Dir(dst), 0755) != nil || os.WriteFile(dst, data, 0600) != nil {
+			return
+		}
+		inputs.Files = append(inputs.Files, filepath.ToSlash(rel))
+	}
+
+	configData, err := os.ReadFile(inputPath(req.ConfigPath))
+	if err != nil {
+		return inputs
+	}
+	copyInput(inputPath(req.ConfigPath), filepath.Join("config", filepath.Base(req.ConfigPath)), false)
+	if planPath := configPlanPath(configData); planPath != "" {
+		inputs.PlanPath = planPath
+		copyInput(inputPath(planPath), filepath.Join("plan", filepath.Base(planPath)), true)
+	}
+	if req.PolicyDir != "" {
+		root := inputPath(req.PolicyDir)
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".rego") {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			copyInput(path, filepath.Join("policies", rel), false)
+			if data, err := os.ReadFile(path); err == nil && !strings.HasSuffix(info.Name(), "_test.rego") {
+				for _, e := range regoPolicyEntries(string(data), info.Name()) {
+					inputs.PolicyIDs = append(inputs.PolicyIDs, e.ID)
+				}
+			}
+			return nil
+		})
+	}
+	return inputs
+}
+
+// Scan parses the stored CLI output.
+func (s *StoredScan) Scan() (*ScanResult, error) {
+	var result ScanResult
+	if err := json.Unmarshal(s.Result, &result); err != nil {
+		return nil, fmt.Errorf("stored scan %s is not valid: %w", s.ID, err)
+	}
+	return &result, nil
+}
+
+// ScanSummary is the headline numbers of a stored scan.
+type ScanSummary struct {
+	ID                   string    `json:"id"`
+	CreatedAt            time.Time `json:"created_at"`
+	Service              string    `json:"service"`
+	ConfigPath           string    `json:"config_path"`
+	AccountID            string    `json:"account_id"`
+	Region               string    `json:"region"`
+	TotalResources       int       `json:"total_resources"`
+	DriftCount           int       `json:"drift_count"`
+	Violations           int       `json:"policy_violations"`
+	Warnings             int       `json:"policy_warnings"`
+	CriticalViolations   int       `json:"critical_violations"`
+	CompliancePercentage float64   `json:"compliance_percentage"`
+}
+
+func summarizeScan(rec *StoredScan) ScanSummary {
+	s := ScanSummary{
+		ID: rec.ID, CreatedAt: rec.CreatedAt, Service: rec.Service, ConfigPath: rec.ConfigPath,
+		CompliancePercentage: 100,
+	}
+	result, err := rec.Scan()
+	if err != nil {
+		return s
+	}
+	s.AccountID = result.AccountID
+	s.Region = result.Region
+	s.TotalResources = result.TotalResources
+	s.DriftCount = result.DriftCount
+	if pr := result.PolicyResult; pr != nil {
+		s.Violations = len(pr.Violations)
+		s.Warnings = len(pr.Warnings)
+		for _, v := range pr.Violations {
+			if strings.EqualFold(v.Severity, "critical") {
+				s.CriticalViolations++
+			}
+		}
+		if pr.Compliance != nil {
+			s.CompliancePercentage = pr.Compliance.OverallPercentage
+		}
+	}
+	return s
+}
+
+// GET /api/scans — List stored scans (summaries only).
+func handleScanList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	scans, err := listScans()
+	if err != nil {
+		jsonError(w, "Failed to list scans: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summaries := []ScanSummary{}
+	for _, rec := range scans {
+		summaries = append(summaries, summarizeScan(rec))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"scans": summaries})
+}
+
+// GET /api/scans/{id} — Return a stored scan with its full CLI result.
+func handleScanGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rec, err := loadScan(r.PathValue("id"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
 }
 
 // ---------------------------------------------------------------------------
@@ -318,6 +1044,12 @@ func handlePlanGet(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "Plan file not found: "+planPath, http.StatusNotFound)
 		return
 	}
+	// Plans written before redaction, or copied into the directory by
+	// hand, are masked on the way out. The file itself is left alone; it is
+	// only redacted on disk when it is next saved.
+	if redacted, paths, err := redactPlanJSON(data); err == nil && len(paths) > 0 {
+		data = redacted
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(data)
 }
@@ -341,32 +1073,641 @@ func handlePlanPut(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "Invalid JSON content", http.StatusBadRequest)
 		return
 	}
-	if err := os.WriteFile(fullPath, body, 0644); err != nil {
+	if errs := validatePlanJSON(body); len(errs) > 0 {
+		planSchemaError(w, errs)
+		return
+	}
+	redacted, err := writePlanFile(fullPath, planPath, body)
+	if err != nil {
 		jsonError(w, "Failed to write plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	resp := map[string]interface{}{"status": "ok"}
+	if len(redacted) > 0 {
+		resp["redacted"] = redacted
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(resp)
 }
 
 // ---------------------------------------------------------------------------
-// File listing endpoint
+// Plan JSON validation
 // ---------------------------------------------------------------------------
 
-func handleFileList(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// planFormatMajor is the major plan format version the CLI reads. Terraform
+// only bumps it for incompatible changes, so any 1.x plan is accepted.
+const planFormatMajor = "1"
+
+// planActions are the actions a resource change can list.
+var planActions = map[string]bool{
+	"no-op": true, "create": true, "read": true, "update": true, "delete": true, "forget": true,
+}
+
+// PlanSchemaError is a part of a plan that does not match the Terraform plan
+// representation. Path is a JSON pointer such as /resource_changes/0/type.
+type PlanSchemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// planValidator collects the errors found while walking a plan.
+type planValidator struct {
+	errs []PlanSchemaError
+}
+
+func (v *planValidator) fail(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, PlanSchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// pointer appends a token to a JSON pointer, escaping "~" and "/".
+func pointer(path string, token interface{}) string {
+	s := strings.NewReplacer("~", "~0", "/", "~1").Replace(fmt.Sprint(token))
+	return path + "/" + s
+}
+
+// validatePlanJSON checks that data has the shape of `terraform show -json`
+// output, as far as the CLI relies on it. Plans built by the Resource
+// Builder have only resource_changes, so format_version and the other top
+// level fields are optional, but checked when present.
+func validatePlanJSON(data []byte) []PlanSchemaError {
+	v := &planValidator{}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		v.fail("", "invalid JSON: %v", err)
+		return v.errs
 	}
-	wd := workDir()
-	if wd == "" {
-		wd = "."
+	plan, ok := doc.(map[string]interface{})
+	if !ok {
+		v.fail("", "plan must be an object")
+		return v.errs
 	}
 
-	type fileInfo struct {
-		Path string `json:"path"`
-		Name string `json:"name"`
-		Size int64  `json:"size"`
+	if fv, ok := plan["format_version"]; ok {
+		s, _ := fv.(string)
+		major, _, _ := strings.Cut(s, ".")
+		switch {
+		case s == "":
+			v.fail("/format_version", "must be a version string")
+		case major != planFormatMajor:
+			v.fail("/format_version", "unsupported format version %s, expected %s.x", s, planFormatMajor)
+		}
+	}
+	v.optionalString(plan, "", "terraform_version")
+
+	_, hasValues := plan["planned_values"]
+	_, hasChanges := plan["resource_changes"]
+	if !hasValues && !hasChanges {
+		v.fail("", "plan must have planned_values or resource_changes")
+	}
+	if hasValues {
+		if values := v.object(plan["planned_values"], "/planned_values"); values != nil {
+			if root, ok := values["root_module"]; ok {
+				if module := v.object(root, "/planned_values/root_module"); module != nil {
+					v.plannedModule(module, "/planned_values/root_module")
+				}
+			}
+		}
+	}
+	if hasChanges {
+		for i, c := range v.array(plan["resource_changes"], "/resource_changes") {
+			v.resourceChange(c, pointer("/resource_changes", i))
+		}
+	}
+	if config, ok := plan["configuration"]; ok {
+		if c := v.object(config, "/configuration"); c != nil {
+			if providers, ok := c["provider_config"]; ok {
+				v.object(providers, "/configuration/provider_config")
+			}
+			if root, ok := c["root_module"]; ok {
+				v.configModule(root, "/configuration/root_module")
+			}
+		}
+	}
+	return v.errs
+}
+
+// plannedModule checks a module of planned_values and its child modules.
+func (v *planValidator) plannedModule(module map[string]interface{}, path string) {
+	for i, r := range v.optionalArray(module, path, "resources") {
+		rp := pointer(pointer(path, "resources"), i)
+		res := v.object(r, rp)
+		if res == nil {
+			continue
+		}
+		v.resourceIdentity(res, rp)
+		v.optionalString(res, rp, "provider_name")
+		for _, key := range []string{"values", "sensitive_values"} {
+			if val, ok := res[key]; ok {
+				v.object(val, pointer(rp, key))
+			}
+		}
+	}
+	for i, c := range v.optionalArray(module, path, "child_modules") {
+		cp := pointer(pointer(path, "child_modules"), i)
+		if child := v.object(c, cp); child != nil {
+			v.requiredString(child, cp, "address")
+			v.plannedModule(child, cp)
+		}
+	}
+}
+
+// resourceChange checks one entry of resource_changes.
+func (v *planValidator) resourceChange(c interface{}, path string) {
+	rc := v.object(c, path)
+	if rc == nil {
+		return
+	}
+	v.resourceIdentity(rc, path)
+	v.optionalString(rc, path, "module_address")
+	v.optionalString(rc, path, "provider_name")
+
+	changePath := pointer(path, "change")
+	raw, ok := rc["change"]
+	if !ok {
+		v.fail(changePath, "is required")
+		return
+	}
+	change := v.object(raw, changePath)
+	if change == nil {
+		return
+	}
+	actionsPath := pointer(changePath, "actions")
+	raw, ok = change["actions"]
+	if !ok {
+		v.fail(actionsPath, "is required")
+	} else if actions := v.array(raw, actionsPath); actions != nil {
+		var names []string
+		for i, a := range actions {
+			name, _ := a.(string)
+			if !planActions[name] {
+				v.fail(pointer(actionsPath, i), "unknown action %v", a)
+			}
+			names = append(names, name)
+		}
+		switch {
+		case len(names) == 0:
+			v.fail(actionsPath, "must not be empty")
+		case len(names) == 2 && !slices.Equal(names, []string{"delete", "create"}) && !slices.Equal(names, []string{"create", "delete"}):
+			v.fail(actionsPath, "a replacement must be [\"delete\", \"create\"] or [\"create\", \"delete\"]")
+		case len(names) > 2:
+			v.fail(actionsPath, "must have one action, or two for a replacement")
+		}
+	}
+	for _, key := range []string{"before", "after"} {
+		if val, ok := change[key]; ok && val != nil {
+			if _, isObject := val.(map[string]interface{}); !isObject {
+				v.fail(pointer(changePath, key), "must be an object or null")
+			}
+		}
+	}
+	for _, key := range []string{"after_unknown", "before_sensitive", "after_sensitive"} {
+		if val, ok := change[key]; ok {
+			switch val.(type) {
+			case bool, map[string]interface{}:
+			default:
+				v.fail(pointer(changePath, key), "must be an object or a boolean")
+			}
+		}
+	}
+}
+
+// resourceIdentity checks the fields shared by planned resources and
+// resource changes.
+func (v *planValidator) resourceIdentity(res map[string]interface{}, path string) {
+	v.requiredString(res, path, "address")
+	v.requiredString(res, path, "type")
+	v.requiredString(res, path, "name")
+	if mode, ok := res["mode"]; ok && mode != "managed" && mode != "data" {
+		v.fail(pointer(path, "mode"), "must be \"managed\" or \"data\"")
+	}
+}
+
+// configModule checks a module of the configuration and its module calls.
+func (v *planValidator) configModule(m interface{}, path string) {
+	module := v.object(m, path)
+	if module == nil {
+		return
+	}
+	for i, r := range v.optionalArray(module, path, "resources") {
+		rp := pointer(pointer(path, "resources"), i)
+		if res := v.object(r, rp); res != nil {
+			v.requiredString(res, rp, "address")
+			v.requiredString(res, rp, "type")
+			v.requiredString(res, rp, "name")
+		}
+	}
+	calls, ok := module["module_calls"]
+	if !ok {
+		return
+	}
+	callsPath := pointer(path, "module_calls")
+	callMap := v.object(calls, callsPath)
+	for _, name := range slices.Sorted(maps.Keys(callMap)) {
+		cp := pointer(callsPath, name)
+		call := v.object(callMap[name], cp)
+		if call == nil {
+			continue
+		}
+		v.optionalString(call, cp, "source")
+		if inner, ok := call["module"]; ok {
+			v.configModule(inner, pointer(cp, "module"))
+		}
+	}
+}
+
+func (v *planValidator) object(val interface{}, path string) map[string]interface{} {
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		v.fail(path, "must be an object")
+	}
+	return m
+}
+
+func (v *planValidator) array(val interface{}, path string) []interface{} {
+	a, ok := val.([]interface{})
+	if !ok {
+		v.fail(path, "must be an array")
+	}
+	return a
+}
+
+func (v *planValidator) optionalArray(m map[string]interface{}, path, key string) []interface{} {
+	if val, ok := m[key]; ok {
+		return v.array(val, pointer(path, key))
+	}
+	return nil
+}
+
+func (v *planValidator) requiredString(m map[string]interface{}, path, key string) {
+	if s, _ := m[key].(string); s == "" {
+		v.fail(pointer(path, key), "must be a non-empty string")
+	}
+}
+
+func (v *planValidator) optionalString(m map[string]interface{}, path, key string) {
+	if val, ok := m[key]; ok {
+		if _, isString := val.(string); !isString {
+			v.fail(pointer(path, key), "must be a string")
+		}
+	}
+}
+
+// planSchemaError rejects a plan that failed validatePlanJSON. The first
+// error is repeated in the message for clients that only show "error".
+func planSchemaError(w http.ResponseWriter, errs []PlanSchemaError) {
+	msg := fmt.Sprintf("Invalid plan at %s: %s", errs[0].Path, errs[0].Message)
+	if errs[0].Path == "" {
+		msg = "Invalid plan: " + errs[0].Message
+	}
+	if len(errs) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(errs)-1)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  msg,
+		"errors": errs,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Sensitive value redaction
+// ---------------------------------------------------------------------------
+
+// redactedValue replaces sensitive plan values, as in Terraform's own
+// output.
+const redactedValue = "(sensitive value)"
+
+// redactedSecret replaces secrets found in command output.
+const redactedSecret = "[REDACTED]"
+
+// redactionsFile is an append-only JSON Lines log of redactions. It records
+// where values were masked, never the values.
+const redactionsFile = "redactions.jsonl"
+
+// secretPattern matches a kind of secret in free text. If the expression has
+// a "secret" group only that part is masked, so the surrounding key stays
+// readable.
+type secretPattern struct {
+	Name string
+	Re   *regexp.Regexp
+}
+
+var secretPatterns = []secretPattern{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"aws_access_key_id", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws_secret_access_key", regexp.MustCompile(`(?i)secret_?access_?key["']?\s*[=:]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})\b`)},
+	{"github_token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"bearer_token", regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{16,}=*)`)},
+	{"url_credentials", regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^/\s:@]+:(?P<secret>[^@\s/]+)@`)},
+	{"assignment", regexp.MustCompile(`(?i)\b[a-z0-9_]*(?:password|passwd|secret|token|api_?key|private_key)[a-z0-9_]*["']?\s*[=:]\s*"(?P<secret>[^"\s]+)"`)},
+}
+
+// RedactionRecord is one entry of the redaction log. Source is "plan" or
+// "job", Target the plan path or job ID. Plans record the JSON pointers of
+// masked values, jobs the number of matches per secret pattern.
+type RedactionRecord struct {
+	RedactedAt time.Time      `json:"redacted_at"`
+	Source     string         `json:"source"`
+	Target     string         `json:"target"`
+	Action     string         `json:"action"`
+	Paths      []string       `json:"paths,omitempty"`
+	Patterns   map[string]int `json:"patterns,omitempty"`
+}
+
+var redactionsMu sync.Mutex
+
+// recordRedaction appends rec to the redaction log.
+func recordRedaction(rec RedactionRecord) {
+	rec.RedactedAt = time.Now().UTC()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("Failed to encode redaction record: %v", err)
+		return
+	}
+
+	redactionsMu.Lock()
+	defer redactionsMu.Unlock()
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		log.Printf("Failed to record redaction: %v", err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dataDir(), redactionsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Failed to record redaction: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to record redaction: %v", err)
+	}
+}
+
+// loadRedactions reads the redaction log newest first, skipping lines that
+// do not parse.
+func loadRedactions() ([]RedactionRecord, error) {
+	redactionsMu.Lock()
+	data, err := os.ReadFile(filepath.Join(dataDir(), redactionsFile))
+	redactionsMu.Unlock()
+	if os.IsNotExist(err) {
+		return []RedactionRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := []RedactionRecord{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var rec RedactionRecord
+		if len(bytes.TrimSpace(line)) > 0 && json.Unmarshal(line, &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+	slices.Reverse(records)
+	return records, nil
+}
+
+// scrubSecrets masks the secretPatterns found in s and counts the matches
+// per pattern.
+func scrubSecrets(s string) (string, map[string]int) {
+	counts := map[string]int{}
+	for _, p := range secretPatterns {
+		group := p.Re.SubexpIndex("secret")
+		var b strings.Builder
+		last := 0
+		for _, m := range p.Re.FindAllStringSubmatchIndex(s, -1) {
+			start, end := m[0], m[1]
+			if group > 0 {
+				start, end = m[2*group], m[2*group+1]
+			}
+			if s[start:end] == redactedSecret {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(redactedSecret)
+			last = end
+			counts[p.Name]++
+		}
+		if last > 0 {
+			b.WriteString(s[last:])
+			s = b.String()
+		}
+	}
+	return s, counts
+}
+
+// planRedactor masks the values of a plan that Terraform marks sensitive
+// and collects their JSON pointers.
+type planRedactor struct {
+	paths []string
+}
+
+// redactPlan masks sensitive values in place: resource values marked in
+// sensitive_values, before_sensitive and after_sensitive, sensitive
+// outputs, and input variables declared sensitive. Values already masked
+// are not reported again.
+func redactPlan(plan map[string]interface{}) []string {
+	r := &planRedactor{}
+	for _, key := range []string{"resource_changes", "resource_drift"} {
+		changes, _ := plan[key].([]interface{})
+		for i, c := range changes {
+			rc, _ := c.(map[string]interface{})
+			change, _ := rc["change"].(map[string]interface{})
+			r.change(change, pointer(pointer(pointer("", key), i), "change"))
+		}
+	}
+	if values, ok := plan["planned_values"].(map[string]interface{}); ok {
+		r.values(values, "/planned_values")
+	}
+	if state, ok := plan["prior_state"].(map[string]interface{}); ok {
+		if values, ok := state["values"].(map[string]interface{}); ok {
+			r.values(values, "/prior_state/values")
+		}
+	}
+	outputChanges, _ := plan["output_changes"].(map[string]interface{})
+	for _, name := range slices.Sorted(maps.Keys(outputChanges)) {
+		change, _ := outputChanges[name].(map[string]interface{})
+		r.change(change, pointer("/output_changes", name))
+	}
+
+	config, _ := plan["configuration"].(map[string]interface{})
+	root, _ := config["root_module"].(map[string]interface{})
+	declared, _ := root["variables"].(map[string]interface{})
+	variables, _ := plan["variables"].(map[string]interface{})
+	for _, name := range slices.Sorted(maps.Keys(variables)) {
+		decl, _ := declared[name].(map[string]interface{})
+		variable, _ := variables[name].(map[string]interface{})
+		if decl["sensitive"] == true && variable != nil {
+			variable["value"] = r.mask(variable["value"], true, pointer(pointer("/variables", name), "value"))
+		}
+	}
+	return r.paths
+}
+
+// change masks the before and after values of a resource or output change.
+func (r *planRedactor) change(change map[string]interface{}, path string) {
+	for _, key := range []string{"before", "after"} {
+		if v, ok := change[key]; ok {
+			change[key] = r.mask(v, change[key+"_sensitive"], pointer(path, key))
+		}
+	}
+}
+
+// values masks the outputs and module resources of planned_values or
+// prior_state.values.
+func (r *planRedactor) values(values map[string]interface{}, path string) {
+	outputs, _ := values["outputs"].(map[string]interface{})
+	for _, name := range slices.Sorted(maps.Keys(outputs)) {
+		if output, _ := outputs[name].(map[string]interface{}); output != nil && output["sensitive"] == true {
+			output["value"] = r.mask(output["value"], true, pointer(pointer(pointer(path, "outputs"), name), "value"))
+		}
+	}
+	if module, ok := values["root_module"].(map[string]interface{}); ok {
+		r.module(module, pointer(path, "root_module"))
+	}
+}
+
+func (r *planRedactor) module(module map[string]interface{}, path string) {
+	resources, _ := module["resources"].([]interface{})
+	for i, res := range resources {
+		if res, _ := res.(map[string]interface{}); res != nil {
+			if values, ok := res["values"]; ok {
+				res["values"] = r.mask(values, res["sensitive_values"], pointer(pointer(pointer(path, "resources"), i), "values"))
+			}
+		}
+	}
+	children, _ := module["child_modules"].([]interface{})
+	for i, child := range children {
+		if child, _ := child.(map[string]interface{}); child != nil {
+			r.module(child, pointer(pointer(path, "child_modules"), i))
+		}
+	}
+}
+
+// mask returns v with the parts marked true in marks masked. Maps and lists
+// keep their shape; only their leaf values are replaced.
+func (r *planRedactor) mask(v, marks interface{}, path string) interface{} {
+	switch m := marks.(type) {
+	case bool:
+		if !m {
+			return v
+		}
+		switch val := v.(type) {
+		case nil:
+			return nil
+		case map[string]interface{}:
+			for _, k := range slices.Sorted(maps.Keys(val)) {
+				val[k] = r.mask(val[k], true, pointer(path, k))
+			}
+			return val
+		case []interface{}:
+			for i := range val {
+				val[i] = r.mask(val[i], true, pointer(path, i))
+			}
+			return val
+		case string:
+			if val == redactedValue {
+				return val
+			}
+		}
+		r.paths = append(r.paths, path)
+		return redactedValue
+	case map[string]interface{}:
+		if val, ok := v.(map[string]interface{}); ok {
+			for _, k := range slices.Sorted(maps.Keys(m)) {
+				if _, ok := val[k]; ok {
+					val[k] = r.mask(val[k], m[k], pointer(path, k))
+				}
+			}
+		}
+	case []interface{}:
+		if val, ok := v.([]interface{}); ok {
+			for i := range min(len(m), len(val)) {
+				val[i] = r.mask(val[i], m[i], pointer(path, i))
+			}
+		}
+	}
+	return v
+}
+
+// redactPlanJSON masks the sensitive values of a plan document. data is
+// returned unchanged if nothing was masked.
+func redactPlanJSON(data []byte) ([]byte, []string, error) {
+	var plan map[string]interface{}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, nil, err
+	}
+	paths := redactPlan(plan)
+	if len(paths) == 0 {
+		return data, nil, nil
+	}
+	redacted, err := json.MarshalIndent(plan, "", "  ")
+	return redacted, paths, err
+}
+
+// writePlanFile redacts a plan and writes it readable by the owner only.
+// rel is the path recorded in the redaction log.
+func writePlanFile(fullPath, rel string, data []byte) ([]string, error) {
+	data, paths, err := redactPlanJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(fullPath, data, 0600); err != nil {
+		return nil, err
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(fullPath, 0600); err != nil {
+		return nil, err
+	}
+	if len(paths) > 0 {
+		recordRedaction(RedactionRecord{Source: "plan", Target: rel, Action: "save", Paths: paths})
+	}
+	return paths, nil
+}
+
+// GET /api/redactions?source=plan|job&target=<path or job id> — List the
+// redaction log, newest first.
+func handleRedactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	source, target := q.Get("source"), q.Get("target")
+	if source != "" && source != "plan" && source != "job" {
+		jsonError(w, "source must be plan or job", http.StatusBadRequest)
+		return
+	}
+	records, err := loadRedactions()
+	if err != nil {
+		jsonError(w, "Failed to read redaction log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	records = slices.DeleteFunc(records, func(rec RedactionRecord) bool {
+		return (source != "" && rec.Source != source) || (target != "" && rec.Target != target)
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"redactions": records})
+}
+
+// ---------------------------------------------------------------------------
+// File listing endpoint
+// ---------------------------------------------------------------------------
+
+func handleFileList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	wd := workDir()
+	if wd == "" {
+		wd = "."
+	}
+
+	type fileInfo struct {
+		Path string `json:"path"`
+		Name string `json:"name"`
+		Size int64  `json:"size"`
 	}
 	result := struct {
 		Configs []fileInfo `json:"configs"`
@@ -441,6 +1782,10 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "Uploaded file is not valid JSON", http.StatusBadRequest)
 		return
 	}
+	if errs := validatePlanJSON(data); len(errs) > 0 {
+		planSchemaError(w, errs)
+		return
+	}
 
 	destPath := filepath.Join("examples", filepath.Base(header.Filename))
 	fullPath, err := safePath(destPath)
@@ -448,39 +1793,82 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+	redacted, err := writePlanFile(fullPath, destPath, data)
+	if err != nil {
 		jsonError(w, "Failed to save file: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	resp := map[string]interface{}{
 		"status": "ok",
 		"path":   destPath,
 		"name":   header.Filename,
-	})
+	}
+	if len(redacted) > 0 {
+		resp["redacted"] = redacted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 // ---------------------------------------------------------------------------
 // Generate plan endpoint
 // ---------------------------------------------------------------------------
 
+// GET /api/files/generate-plan — List the resource types plans can be built
+// from, with their default specs.
+// POST /api/files/generate-plan — Write a plan, given as a raw plan map or
+// built from typed resource specs, to examples/generated-plan.json.
 func handleGeneratePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		types := map[string]interface{}{}
+		for t, newSpec := range resourceSpecTypes {
+			types[t] = newSpec()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"resource_types": types})
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		Service string                 `json:"service"`
-		Plan    map[string]interface{} `json:"plan"`
+		Service          string                 `json:"service"`
+		Plan             map[string]interface{} `json:"plan"`
+		Resources        []PlanResource         `json:"resources"`
+		TerraformVersion string                 `json:"terraform_version"`
+		Preview          bool                   `json:"preview"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	if req.Resources != nil {
+		if req.Plan != nil {
+			jsonError(w, "Send either plan or resources, not both", http.StatusBadRequest)
+			return
+		}
+		plan, err := buildPlan(req.Resources, req.TerraformVersion)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Plan = plan
+		if req.Service == "" {
+			req.Service = planService(req.Resources)
+		}
+	} else if req.Service == "" {
+		req.Service = "s3"
+	}
 	if req.Plan == nil {
-		jsonError(w, "plan is required", http.StatusBadRequest)
+		jsonError(w, "plan or resources is required", http.StatusBadRequest)
+		return
+	}
+	if req.Preview {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "plan": req.Plan})
 		return
 	}
 
@@ -497,355 +1885,8647 @@ func handleGeneratePlan(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := os.WriteFile(fullPlanPath, planBytes, 0644); err != nil {
+	if _, err := writePlanFile(fullPlanPath, planPath, planBytes); err != nil {
 		jsonError(w, "Failed to write plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Update the matching config file's plan_path
-	configFile := "config/cloudrift-s3.yml"
-	if strings.EqualFold(req.Service, "ec2") {
-		configFile = "config/cloudrift-ec2.yml"
-	} else if strings.EqualFold(req.Service, "iam") {
-		configFile = "config/cloudrift-iam.yml"
-	}
-	fullConfigPath, err := safePath(configFile)
-	if err == nil {
-		configData, readErr := os.ReadFile(fullConfigPath)
-		if readErr == nil {
-			// Simple YAML update: replace the plan_path line
-			lines := strings.Split(string(configData), "\n")
-			updated := false
-			for i, line := range lines {
-				if strings.HasPrefix(strings.TrimSpace(line), "plan_path:") {
-					lines[i] = "plan_path: ./examples/generated-plan.json"
-					updated = true
-					break
-				}
-			}
-			if !updated {
-				lines = append(lines, "plan_path: ./examples/generated-plan.json")
-			}
-			os.WriteFile(fullConfigPath, []byte(strings.Join(lines, "\n")), 0644)
+	resp := map[string]string{"status": "ok", "plan_path": planPath}
+
+	// Update the matching config file's plan_path. Plans for other
+	// services leave every config untouched.
+	if configFile, ok := planServiceConfigs[strings.ToLower(req.Service)]; ok {
+		if err := setConfigPlanPath(configFile, planPath); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":     "Plan saved but config not updated: " + err.Error(),
+				"plan_path": planPath,
+			})
+			return
 		}
+		resp["config"] = configFile
+	} else if req.Service == "" {
+		resp["config_skipped"] = "resource types do not map to a single service (s3, ec2 or iam); set service to update its config"
+	} else {
+		resp["config_skipped"] = "no config for service " + req.Service
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":    "ok",
-		"plan_path": planPath,
-		"config":    configFile,
-	})
+	json.NewEncoder(w).Encode(resp)
+}
+
+// setConfigPlanPath points the plan_path of a config file at planPath.
+func setConfigPlanPath(configFile, planPath string) error {
+	fullConfigPath, err := safePath(configFile)
+	if err != nil {
+		return err
+	}
+	configData, err := os.ReadFile(fullConfigPath)
+	if err != nil {
+		return err
+	}
+	// Simple YAML update: replace the plan_path line
+	lines := strings.Split(string(configData), "\n")
+	updated := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "plan_path:") {
+			lines[i] = "plan_path: ./" + planPath
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		lines = append(lines, "plan_path: ./"+planPath)
+	}
+	return os.WriteFile(fullConfigPath, []byte(strings.Join(lines, "\n")), 0644)
 }
 
 // ---------------------------------------------------------------------------
-// Terraform endpoints
+// Plan resource specs
 // ---------------------------------------------------------------------------
 
-func terraformPath() string {
-	if p := os.Getenv("TERRAFORM_PATH"); p != "" {
-		return p
-	}
-	return "terraform"
+// defaultPlanTerraformVersion is reported in generated plans unless the
+// request names another version.
+const defaultPlanTerraformVersion = "1.9.8"
+
+// awsProviderName is the provider of every generated resource.
+const awsProviderName = "registry.terraform.io/hashicorp/aws"
+
+// ResourceSpec is a typed resource definition that renders to the values
+// of a planned resource.
+type ResourceSpec interface {
+	// identity is the resource's own name (bucket, role name, ...), used
+	// to derive the Terraform name when none is given.
+	identity() string
+	validate() error
+	values() map[string]interface{}
 }
 
-// GET /api/terraform/status — Check Terraform availability and list .tf files.
-func handleTerraformStatus(w http.ResponseWriter, r *http.Request) {
+// sensitiveSpec is implemented by specs with sensitive attributes.
+type sensitiveSpec interface {
+	sensitiveValues() map[string]interface{}
+}
+
+// resourceSpecTypes maps Terraform resource types to a constructor that
+// returns the spec with its defaults.
+var resourceSpecTypes = map[string]func() ResourceSpec{
+	"aws_s3_bucket": func() ResourceSpec {
+		return &S3BucketSpec{ACL: "private", SSEAlgorithm: "AES256", BlockPublicAcls: true,
+			IgnorePublicAcls: true, BlockPublicPolicy: true, RestrictPublicBuckets: true}
+	},
+	"aws_instance": func() ResourceSpec {
+		return &EC2InstanceSpec{InstanceType: "t3.micro", RootVolumeType: "gp3", RootVolumeSize: 20,
+			RootVolumeEncrypted: true, HTTPTokens: "required"}
+	},
+	"aws_iam_role":   func() ResourceSpec { return &IAMRoleSpec{Path: "/", MaxSessionDuration: 3600} },
+	"aws_iam_user":   func() ResourceSpec { return &IAMUserSpec{Path: "/"} },
+	"aws_iam_policy": func() ResourceSpec { return &IAMPolicySpec{Path: "/"} },
+	"aws_iam_group":  func() ResourceSpec { return &IAMGroupSpec{Path: "/"} },
+	"aws_security_group": func() ResourceSpec {
+		return &SecurityGroupSpec{Description: "Managed by Terraform"}
+	},
+	"aws_db_instance": func() ResourceSpec {
+		return &RDSInstanceSpec{Engine: "postgres", InstanceClass: "db.t3.micro", AllocatedStorage: 20,
+			StorageType: "gp3", StorageEncrypted: true, Username: "dbadmin", BackupRetentionPeriod: 7,
+			DeletionProtection: true}
+	},
+	"aws_lambda_function": func() ResourceSpec {
+		return &LambdaFunctionSpec{Runtime: "python3.12", Handler: "index.handler", MemorySize: 128,
+			Timeout: 3, TracingMode: "PassThrough", ReservedConcurrentExecutions: -1}
+	},
+}
+
+// planComputedAttributes are known only after apply and are marked unknown in
+// after_unknown.
+var planComputedAttributes = map[string][]string{
+	"aws_s3_bucket":       {"arn", "bucket_domain_name", "bucket_regional_domain_name", "hosted_zone_id", "id", "region"},
+	"aws_instance":        {"arn", "id", "primary_network_interface_id", "private_ip", "public_ip"},
+	"aws_iam_role":        {"arn", "create_date", "id", "unique_id"},
+	"aws_iam_user":        {"arn", "id", "unique_id"},
+	"aws_iam_policy":      {"arn", "id", "policy_id"},
+	"aws_iam_group":       {"arn", "id", "unique_id"},
+	"aws_security_group":  {"arn", "id", "owner_id"},
+	"aws_db_instance":     {"address", "arn", "endpoint", "id", "resource_id"},
+	"aws_lambda_function": {"arn", "id", "invoke_arn", "last_modified", "qualified_arn", "version"},
+}
+
+// S3BucketSpec mirrors the UI's S3 resource definition. An empty
+// sse_algorithm leaves encryption out, for plans that test S3-001.
+type S3BucketSpec struct {
+	Bucket                string              `json:"bucket"`
+	ACL                   string              `json:"acl"`
+	VersioningEnabled     bool                `json:"versioning_enabled"`
+	SSEAlgorithm          string              `json:"sse_algorithm"`
+	LoggingTargetBucket   string              `json:"logging_target_bucket"`
+	LoggingTargetPrefix   string              `json:"logging_target_prefix"`
+	BlockPublicAcls       bool                `json:"block_public_acls"`
+	IgnorePublicAcls      bool                `json:"ignore_public_acls"`
+	BlockPublicPolicy     bool                `json:"block_public_policy"`
+	RestrictPublicBuckets bool                `json:"restrict_public_buckets"`
+	Tags                  map[string]string   `json:"tags"`
+	LifecycleRules        []LifecycleRuleSpec `json:"lifecycle_rules"`
+}
+
+// LifecycleRuleSpec is an S3 lifecycle rule that expires objects.
+type LifecycleRuleSpec struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Prefix         string `json:"prefix"`
+	ExpirationDays int    `json:"expiration_days"`
+}
+
+func (s *S3BucketSpec) identity() string { return s.Bucket }
+
+func (s *S3BucketSpec) validate() error {
+	if s.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if !slices.Contains([]string{"private", "public-read", "public-read-write", "authenticated-read",
+		"aws-exec-read", "bucket-owner-read", "bucket-owner-full-control", "log-delivery-write"}, s.ACL) {
+		return fmt.Errorf("invalid acl: %s", s.ACL)
+	}
+	if !slices.Contains([]string{"", "AES256", "aws:kms", "aws:kms:dsse"}, s.SSEAlgorithm) {
+		return fmt.Errorf("invalid sse_algorithm: %s", s.SSEAlgorithm)
+	}
+	for i, rule := range s.LifecycleRules {
+		if rule.Status != "" && rule.Status != "Enabled" && rule.Status != "Disabled" {
+			return fmt.Errorf("lifecycle_rules[%d]: status must be Enabled or Disabled", i)
+		}
+		if rule.ExpirationDays < 0 {
+			return fmt.Errorf("lifecycle_rules[%d]: expiration_days must not be negative", i)
+		}
+	}
+	return nil
+}
+
+func (s *S3BucketSpec) values() map[string]interface{} {
+	after := map[string]interface{}{
+		"bucket":     s.Bucket,
+		"acl":        s.ACL,
+		"versioning": map[string]interface{}{"enabled": s.VersioningEnabled},
+		"public_access_block": map[string]interface{}{
+			"block_public_acls":       s.BlockPublicAcls,
+			"ignore_public_acls":      s.IgnorePublicAcls,
+			"block_public_policy":     s.BlockPublicPolicy,
+			"restrict_public_buckets": s.RestrictPublicBuckets,
+		},
+	}
+	if s.SSEAlgorithm != "" {
+		after["server_side_encryption_configuration"] = map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{
+				"apply_server_side_encryption_by_default": map[string]interface{}{"sse_algorithm": s.SSEAlgorithm},
+			}},
+		}
+	}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	if s.LoggingTargetBucket != "" {
+		after["logging"] = map[string]interface{}{
+			"target_bucket": s.LoggingTargetBucket,
+			"target_prefix": s.LoggingTargetPrefix,
+		}
+	}
+	if len(s.LifecycleRules) > 0 {
+		rules := []interface{}{}
+		for _, rule := range s.LifecycleRules {
+			status := rule.Status
+			if status == "" {
+				status = "Enabled"
+			}
+			days := rule.ExpirationDays
+			if days == 0 {
+				days = 90
+			}
+			rules = append(rules, map[string]interface{}{
+				"id": rule.ID, "status": status, "prefix": rule.Prefix,
+				"expiration": map[string]interface{}{"days": days},
+			})
+		}
+		after["lifecycle_rule"] = rules
+	}
+	return after
+}
+
+// EC2InstanceSpec mirrors the UI's EC2 resource definition, plus the
+// metadata and public IP settings checked by EC2-001 and EC2-003.
+type EC2InstanceSpec struct {
+	InstanceType             string            `json:"instance_type"`
+	AMI                      string            `json:"ami"`
+	SubnetID                 string            `json:"subnet_id"`
+	AvailabilityZone         string            `json:"availability_zone"`
+	KeyName                  string            `json:"key_name"`
+	IAMInstanceProfile       string            `json:"iam_instance_profile"`
+	EBSOptimized             bool              `json:"ebs_optimized"`
+	Monitoring               bool              `json:"monitoring"`
+	AssociatePublicIPAddress bool              `json:"associate_public_ip_address"`
+	HTTPTokens               string            `json:"http_tokens"`
+	SecurityGroupIDs         []string          `json:"security_group_ids"`
+	Tags                     map[string]string `json:"tags"`
+	RootVolumeType           string            `json:"root_volume_type"`
+	RootVolumeSize           int               `json:"root_volume_size"`
+	RootVolumeEncrypted      bool              `json:"root_volume_encrypted"`
+}
+
+func (s *EC2InstanceSpec) identity() string {
+	if name := s.Tags["Name"]; name != "" {
+		return name
+	}
+	return "server"
+}
+
+func (s *EC2InstanceSpec) validate() error {
+	if s.InstanceType == "" {
+		return fmt.Errorf("instance_type is required")
+	}
+	if s.HTTPTokens != "required" && s.HTTPTokens != "optional" {
+		return fmt.Errorf("http_tokens must be required or optional")
+	}
+	if !slices.Contains([]string{"gp2", "gp3", "io1", "io2", "st1", "sc1", "standard"}, s.RootVolumeType) {
+		return fmt.Errorf("invalid root_volume_type: %s", s.RootVolumeType)
+	}
+	if s.RootVolumeSize < 1 {
+		return fmt.Errorf("root_volume_size must be at least 1")
+	}
+	return nil
+}
+
+func (s *EC2InstanceSpec) values() map[string]interface{} {
+	after := map[string]interface{}{
+		"instance_type":               s.InstanceType,
+		"ami":                         s.AMI,
+		"ebs_optimized":               s.EBSOptimized,
+		"monitoring":                  s.Monitoring,
+		"associate_public_ip_address": s.AssociatePublicIPAddress,
+		"metadata_options": []interface{}{map[string]interface{}{
+			"http_endpoint":               "enabled",
+			"http_tokens":                 s.HTTPTokens,
+			"http_put_response_hop_limit": 1,
+		}},
+		"root_block_device": []interface{}{map[string]interface{}{
+			"volume_type":           s.RootVolumeType,
+			"volume_size":           s.RootVolumeSize,
+			"encrypted":             s.RootVolumeEncrypted,
+			"delete_on_termination": true,
+		}},
+	}
+	if s.SubnetID != "" {
+		after["subnet_id"] = s.SubnetID
+	}
+	if s.AvailabilityZone != "" {
+		after["availability_zone"] = s.AvailabilityZone
+	}
+	if s.KeyName != "" {
+		after["key_name"] = s.KeyName
+	}
+	if s.IAMInstanceProfile != "" {
+		after["iam_instance_profile"] = s.IAMInstanceProfile
+	}
+	if len(s.SecurityGroupIDs) > 0 {
+		after["vpc_security_group_ids"] = s.SecurityGroupIDs
+	}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	return after
+}
+
+// IAMRoleSpec mirrors the UI's IAM role definition.
+type IAMRoleSpec struct {
+	Name               string            `json:"name"`
+	Path               string            `json:"path"`
+	AssumeRolePolicy   string            `json:"assume_role_policy"`
+	MaxSessionDuration int               `json:"max_session_duration"`
+	Description        string            `json:"description"`
+	Tags               map[string]string `json:"tags"`
+	AttachedPolicies   []string          `json:"attached_policies"`
+}
+
+func (s *IAMRoleSpec) identity() string { return s.Name }
+
+func (s *IAMRoleSpec) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.MaxSessionDuration < 3600 || s.MaxSessionDuration > 43200 {
+		return fmt.Errorf("max_session_duration must be between 3600 and 43200")
+	}
+	return validatePolicyDocument("assume_role_policy", s.AssumeRolePolicy)
+}
+
+func (s *IAMRoleSpec) values() map[string]interface{} {
+	after := map[string]interface{}{
+		"name":                 s.Name,
+		"path":                 s.Path,
+		"max_session_duration": s.MaxSessionDuration,
+	}
+	if s.AssumeRolePolicy != "" {
+		after["assume_role_policy"] = s.AssumeRolePolicy
+	}
+	if s.Description != "" {
+		after["description"] = s.Description
+	}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	if len(s.AttachedPolicies) > 0 {
+		after["attached_policies"] = s.AttachedPolicies
+	}
+	return after
+}
+
+// IAMUserSpec mirrors the UI's IAM user definition.
+type IAMUserSpec struct {
+	Name             string            `json:"name"`
+	Path             string            `json:"path"`
+	Tags             map[string]string `json:"tags"`
+	AttachedPolicies []string          `json:"attached_policies"`
+}
+
+func (s *IAMUserSpec) identity() string { return s.Name }
+
+func (s *IAMUserSpec) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+func (s *IAMUserSpec) values() map[string]interface{} {
+	after := map[string]interface{}{"name": s.Name, "path": s.Path}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	if len(s.AttachedPolicies) > 0 {
+		after["attached_policies"] = s.AttachedPolicies
+	}
+	return after
+}
+
+// IAMPolicySpec mirrors the UI's IAM policy definition.
+type IAMPolicySpec struct {
+	Name        string            `json:"name"`
+	Path        string            `json:"path"`
+	Policy      string            `json:"policy"`
+	Description string            `json:"description"`
+	Tags        map[string]string `json:"tags"`
+}
+
+func (s *IAMPolicySpec) identity() string { return s.Name }
+
+func (s *IAMPolicySpec) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return validatePolicyDocument("policy", s.Policy)
+}
+
+func (s *IAMPolicySpec) values() map[string]interface{} {
+	after := map[string]interface{}{"name": s.Name, "path": s.Path}
+	if s.Policy != "" {
+		after["policy"] = s.Policy
+	}
+	if s.Description != "" {
+		after["description"] = s.Description
+	}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	return after
+}
+
+// IAMGroupSpec mirrors the UI's IAM group definition.
+type IAMGroupSpec struct {
+	Name             string   `json:"name"`
+	Path             string   `json:"path"`
+	AttachedPolicies []string `json:"attached_policies"`
+	Members          []string `json:"members"`
+}
+
+func (s *IAMGroupSpec) identity() string { return s.Name }
+
+func (s *IAMGroupSpec) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+func (s *IAMGroupSpec) values() map[string]interface{} {
+	after := map[string]interface{}{"name": s.Name, "path": s.Path}
+	if len(s.AttachedPolicies) > 0 {
+		after["attached_policies"] = s.AttachedPolicies
+	}
+	if len(s.Members) > 0 {
+		after["members"] = s.Members
+	}
+	return after
+}
+
+// validatePolicyDocument checks that an optional IAM policy document is a
+// JSON object.
+func validatePolicyDocument(field, doc string) error {
+	if doc == "" {
+		return nil
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return fmt.Errorf("%s is not a JSON policy document: %v", field, err)
+	}
+	return nil
+}
+
+// SecurityGroupSpec is a security group with inline rules.
+type SecurityGroupSpec struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	VPCID       string                  `json:"vpc_id"`
+	Ingress     []SecurityGroupRuleSpec `json:"ingress"`
+	Egress      []SecurityGroupRuleSpec `json:"egress"`
+	Tags        map[string]string       `json:"tags"`
+}
+
+// SecurityGroupRuleSpec is an ingress or egress rule. Protocol defaults to
+// tcp; "-1" means all protocols and ports.
+type SecurityGroupRuleSpec struct {
+	Description    string   `json:"description"`
+	FromPort       int      `json:"from_port"`
+	ToPort         int      `json:"to_port"`
+	Protocol       string   `json:"protocol"`
+	CIDRBlocks     []string `json:"cidr_blocks"`
+	IPv6CIDRBlocks []string `json:"ipv6_cidr_blocks"`
+}
+
+func (s *SecurityGroupSpec) identity() string { return s.Name }
+
+func (s *SecurityGroupSpec) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	for field, rules := range map[string][]SecurityGroupRuleSpec{"ingress": s.Ingress, "egress": s.Egress} {
+		for i, rule := range rules {
+			if err := rule.validate(); err != nil {
+				return fmt.Errorf("%s[%d]: %v", field, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r SecurityGroupRuleSpec) validate() error {
+	if !slices.Contains([]string{"", "tcp", "udp", "icmp", "-1"}, r.Protocol) {
+		return fmt.Errorf("protocol must be tcp, udp, icmp or -1")
+	}
+	if r.FromPort < -1 || r.ToPort > 65535 || r.FromPort > r.ToPort {
+		return fmt.Errorf("invalid port range %d-%d", r.FromPort, r.ToPort)
+	}
+	for _, cidr := range append(slices.Clone(r.CIDRBlocks), r.IPv6CIDRBlocks...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR: %s", cidr)
+		}
+	}
+	return nil
+}
+
+// values renders the rule with every attribute Terraform reports for
+// inline rules.
+func (r SecurityGroupRuleSpec) values() map[string]interface{} {
+	protocol := r.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	orEmpty := func(s []string) []string {
+		if s == nil {
+			return []string{}
+		}
+		return s
+	}
+	return map[string]interface{}{
+		"cidr_blocks":      orEmpty(r.CIDRBlocks),
+		"description":      r.Description,
+		"from_port":        r.FromPort,
+		"ipv6_cidr_blocks": orEmpty(r.IPv6CIDRBlocks),
+		"prefix_list_ids":  []string{},
+		"protocol":         protocol,
+		"security_groups":  []string{},
+		"self":             false,
+		"to_port":          r.ToPort,
+	}
+}
+
+func (s *SecurityGroupSpec) values() map[string]interface{} {
+	ingress, egress := []interface{}{}, []interface{}{}
+	for _, rule := range s.Ingress {
+		ingress = append(ingress, rule.values())
+	}
+	for _, rule := range s.Egress {
+		egress = append(egress, rule.values())
+	}
+	after := map[string]interface{}{
+		"name":        s.Name,
+		"description": s.Description,
+		"ingress":     ingress,
+		"egress":      egress,
+	}
+	if s.VPCID != "" {
+		after["vpc_id"] = s.VPCID
+	}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	return after
+}
+
+// RDSInstanceSpec is a database instance with the settings checked by the
+// RDS policies. The password is marked sensitive in the plan.
+type RDSInstanceSpec struct {
+	Identifier            string            `json:"identifier"`
+	Engine                string            `json:"engine"`
+	EngineVersion         string            `json:"engine_version"`
+	InstanceClass         string            `json:"instance_class"`
+	AllocatedStorage      int               `json:"allocated_storage"`
+	StorageType           string            `json:"storage_type"`
+	StorageEncrypted      bool              `json:"storage_encrypted"`
+	KMSKeyID              string            `json:"kms_key_id"`
+	Username              string            `json:"username"`
+	Password              string            `json:"password"`
+	PubliclyAccessible    bool              `json:"publicly_accessible"`
+	MultiAZ               bool              `json:"multi_az"`
+	BackupRetentionPeriod int               `json:"backup_retention_period"`
+	DeletionProtection    bool              `json:"deletion_protection"`
+	DBSubnetGroupName     string            `json:"db_subnet_group_name"`
+	SecurityGroupIDs      []string          `json:"security_group_ids"`
+	Tags                  map[string]string `json:"tags"`
+}
+
+func (s *RDSInstanceSpec) identity() string { return s.Identifier }
+
+func (s *RDSInstanceSpec) validate() error {
+	if s.Identifier == "" {
+		return fmt.Errorf("identifier is required")
+	}
+	if !slices.Contains([]string{"postgres", "mysql", "mariadb", "oracle-ee", "oracle-se2",
+		"sqlserver-ee", "sqlserver-se", "sqlserver-ex", "sqlserver-web"}, s.Engine) {
+		return fmt.Errorf("unsupported engine: %s", s.Engine)
+	}
+	if s.InstanceClass == "" || !strings.HasPrefix(s.InstanceClass, "db.") {
+		return fmt.Errorf("instance_class must start with db.")
+	}
+	if s.AllocatedStorage < 20 {
+		return fmt.Errorf("allocated_storage must be at least 20")
+	}
+	if s.BackupRetentionPeriod < 0 || s.BackupRetentionPeriod > 35 {
+		return fmt.Errorf("backup_retention_period must be between 0 and 35")
+	}
+	return nil
+}
+
+func (s *RDSInstanceSpec) values() map[string]interface{} {
+	after := map[string]interface{}{
+		"identifier":              s.Identifier,
+		"engine":                  s.Engine,
+		"instance_class":          s.InstanceClass,
+		"allocated_storage":       s.AllocatedStorage,
+		"storage_type":            s.StorageType,
+		"storage_encrypted":       s.StorageEncrypted,
+		"username":                s.Username,
+		"publicly_accessible":     s.PubliclyAccessible,
+		"multi_az":                s.MultiAZ,
+		"backup_retention_period": s.BackupRetentionPeriod,
+		"deletion_protection":     s.DeletionProtection,
+		"skip_final_snapshot":     !s.DeletionProtection,
+	}
+	if s.EngineVersion != "" {
+		after["engine_version"] = s.EngineVersion
+	}
+	if s.KMSKeyID != "" {
+		after["kms_key_id"] = s.KMSKeyID
+	}
+	if s.Password != "" {
+		after["password"] = s.Password
+	}
+	if s.DBSubnetGroupName != "" {
+		after["db_subnet_group_name"] = s.DBSubnetGroupName
+	}
+	if len(s.SecurityGroupIDs) > 0 {
+		after["vpc_security_group_ids"] = s.SecurityGroupIDs
+	}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	return after
+}
+
+func (s *RDSInstanceSpec) sensitiveValues() map[string]interface{} {
+	if s.Password == "" {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"password": true}
+}
+
+// LambdaFunctionSpec is a Lambda function with the tracing and VPC settings
+// checked by the Lambda policies. Environment variables are sensitive.
+type LambdaFunctionSpec struct {
+	FunctionName                 string            `json:"function_name"`
+	Runtime                      string            `json:"runtime"`
+	Handler                      string            `json:"handler"`
+	Role                         string            `json:"role"`
+	MemorySize                   int               `json:"memory_size"`
+	Timeout                      int               `json:"timeout"`
+	TracingMode                  string            `json:"tracing_mode"`
+	KMSKeyARN                    string            `json:"kms_key_arn"`
+	ReservedConcurrentExecutions int               `json:"reserved_concurrent_executions"`
+	SubnetIDs                    []string          `json:"subnet_ids"`
+	SecurityGroupIDs             []string          `json:"security_group_ids"`
+	Environment                  map[string]string `json:"environment"`
+	Tags                         map[string]string `json:"tags"`
+}
+
+func (s *LambdaFunctionSpec) identity() string { return s.FunctionName }
+
+func (s *LambdaFunctionSpec) validate() error {
+	if s.FunctionName == "" {
+		return fmt.Errorf("function_name is required")
+	}
+	if s.Role == "" {
+		return fmt.Errorf("role is required")
+	}
+	if s.MemorySize < 128 || s.MemorySize > 10240 {
+		return fmt.Errorf("memory_size must be between 128 and 10240")
+	}
+	if s.Timeout < 1 || s.Timeout > 900 {
+		return fmt.Errorf("timeout must be between 1 and 900")
+	}
+	if s.TracingMode != "Active" && s.TracingMode != "PassThrough" {
+		return fmt.Errorf("tracing_mode must be Active or PassThrough")
+	}
+	if (len(s.SubnetIDs) == 0) != (len(s.SecurityGroupIDs) == 0) {
+		return fmt.Errorf("subnet_ids and security_group_ids must be set together")
+	}
+	return nil
+}
+
+func (s *LambdaFunctionSpec) values() map[string]interface{} {
+	after := map[string]interface{}{
+		"function_name":                  s.FunctionName,
+		"runtime":                        s.Runtime,
+		"handler":                        s.Handler,
+		"role":                           s.Role,
+		"memory_size":                    s.MemorySize,
+		"timeout":                        s.Timeout,
+		"reserved_concurrent_executions": s.ReservedConcurrentExecutions,
+		"tracing_config":                 []interface{}{map[string]interface{}{"mode": s.TracingMode}},
+		"vpc_config":                     []interface{}{},
+	}
+	if len(s.SubnetIDs) > 0 {
+		after["vpc_config"] = []interface{}{map[string]interface{}{
+			"subnet_ids":         s.SubnetIDs,
+			"security_group_ids": s.SecurityGroupIDs,
+		}}
+	}
+	if s.KMSKeyARN != "" {
+		after["kms_key_arn"] = s.KMSKeyARN
+	}
+	if len(s.Environment) > 0 {
+		after["environment"] = []interface{}{map[string]interface{}{"variables": s.Environment}}
+	}
+	if len(s.Tags) > 0 {
+		after["tags"] = s.Tags
+	}
+	return after
+}
+
+func (s *LambdaFunctionSpec) sensitiveValues() map[string]interface{} {
+	if len(s.Environment) == 0 {
+		return map[string]interface{}{}
+	}
+	variables := map[string]interface{}{}
+	for k := range s.Environment {
+		variables[k] = true
+	}
+	return map[string]interface{}{"environment": []interface{}{map[string]interface{}{"variables": variables}}}
+}
+
+// PlanResource is one resource of a plan build request. Spec is decoded
+// into the typed spec of Type over its defaults.
+type PlanResource struct {
+	Type string          `json:"type"`
+	Name string          `json:"name"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// decodeResourceSpec decodes the spec of r over its defaults, rejecting
+// unknown fields so typos are not silently ignored.
+func decodeResourceSpec(r PlanResource) (ResourceSpec, error) {
+	newSpec, ok := resourceSpecTypes[r.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported resource type: %s", r.Type)
+	}
+	spec := newSpec()
+	if len(r.Spec) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(r.Spec))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(spec); err != nil {
+			return nil, fmt.Errorf("invalid spec: %v", err)
+		}
+	}
+	return spec, nil
+}
+
+// nonIdentifierChar matches the characters terraformName replaces.
+var nonIdentifierChar = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// terraformName derives a resource name from a spec's identity.
+func terraformName(identity string) string {
+	name := nonIdentifierChar.ReplaceAllString(identity, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// planEntry is a decoded and validated plan resource.
+type planEntry struct {
+	Type, Name string
+	spec       ResourceSpec
+}
+
+// checkPlanResources decodes and validates resources, deriving missing
+// names. It returns every problem found, prefixed with the resource's index.
+func checkPlanResources(resources []PlanResource) ([]planEntry, []string) {
+	if len(resources) == 0 {
+		return nil, []string{"at least one resource is required"}
+	}
+	var entries []planEntry
+	problems := []string{}
+	seen := map[string]bool{}
+	for i, r := range resources {
+		spec, err := decodeResourceSpec(r)
+		if err == nil {
+			err = spec.validate()
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("resources[%d]: %v", i, err))
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = terraformName(spec.identity())
+		}
+		if !hclIdentifier.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("resources[%d]: invalid name: %q", i, name))
+			continue
+		}
+		address := r.Type + "." + name
+		if seen[address] {
+			problems = append(problems, fmt.Sprintf("resources[%d]: duplicate address %s", i, address))
+			continue
+		}
+		seen[address] = true
+		entries = append(entries, planEntry{Type: r.Type, Name: name, spec: spec})
+	}
+	return entries, problems
+}
+
+// buildPlan renders typed resources as a Terraform plan JSON document in
+// which every resource is created.
+func buildPlan(resources []PlanResource, tfVersion string) (map[string]interface{}, error) {
+	entries, problems := checkPlanResources(resources)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", problems[0])
+	}
+	if tfVersion == "" {
+		tfVersion = defaultPlanTerraformVersion
+	}
+	planned := []interface{}{}
+	changes := []interface{}{}
+	for _, e := range entries {
+		address := e.Type + "." + e.Name
+		values := e.spec.values()
+		sensitive := map[string]interface{}{}
+		if s, ok := e.spec.(sensitiveSpec); ok {
+			sensitive = s.sensitiveValues()
+		}
+		unknown := map[string]interface{}{}
+		for _, attr := range planComputedAttributes[e.Type] {
+			unknown[attr] = true
+		}
+		planned = append(planned, map[string]interface{}{
+			"address":          address,
+			"mode":             "managed",
+			"type":             e.Type,
+			"name":             e.Name,
+			"provider_name":    awsProviderName,
+			"schema_version":   0,
+			"values":           values,
+			"sensitive_values": sensitive,
+		})
+		changes = append(changes, map[string]interface{}{
+			"address":       address,
+			"mode":          "managed",
+			"type":          e.Type,
+			"name":          e.Name,
+			"provider_name": awsProviderName,
+			"change": map[string]interface{}{
+				"actions":          []string{"create"},
+				"before":           nil,
+				"after":            values,
+				"after_unknown":    unknown,
+				"before_sensitive": false,
+				"after_sensitive":  sensitive,
+			},
+		})
+	}
+	return map[string]interface{}{
+		"format_version":    "1.2",
+		"terraform_version": tfVersion,
+		"planned_values": map[string]interface{}{
+			"root_module": map[string]interface{}{"resources": planned},
+		},
+		"resource_changes": changes,
+		"configuration": map[string]interface{}{
+			"provider_config": map[string]interface{}{
+				"aws": map[string]interface{}{"name": "aws", "full_name": awsProviderName},
+			},
+			"root_module": map[string]interface{}{},
+		},
+	}, nil
+}
+
+// planServiceConfigs maps the scan services to the config file whose
+// plan_path a generated plan updates.
+var planServiceConfigs = map[string]string{
+	"s3":  "config/cloudrift-s3.yml",
+	"ec2": "config/cloudrift-ec2.yml",
+	"iam": "config/cloudrift-iam.yml",
+}
+
+// planService picks the scan service whose config should point at a plan
+// built from the given resources. It returns "" unless every resource
+// belongs to the same one of s3, ec2 and iam, so that, for example, an
+// RDS-only plan does not replace the S3 config's plan.
+func planService(resources []PlanResource) string {
+	service := ""
+	for _, res := range resources {
+		var s string
+		switch {
+		case res.Type == "aws_s3_bucket":
+			s = "s3"
+		case res.Type == "aws_instance" || res.Type == "aws_security_group":
+			s = "ec2"
+		case strings.HasPrefix(res.Type, "aws_iam_"):
+			s = "iam"
+		default:
+			return ""
+		}
+		if service != "" && s != service {
+			return ""
+		}
+		service = s
+	}
+	return service
+}
+
+// ---------------------------------------------------------------------------
+// Draft plans
+// ---------------------------------------------------------------------------
+
+const draftsFile = "drafts.json"
+
+// draftPlanDir holds saved drafts, relative to the work directory.
+const draftPlanDir = "examples/drafts"
+
+// DraftPlan is a plan composed resource by resource. Resources keep their
+// spec as sent, so a draft can hold resources that do not validate yet;
+// saving builds the plan and fails until they do.
+type DraftPlan struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Resources   []PlanResource `json:"resources"`
+	SavedPath   string         `json:"saved_path,omitempty"`
+	SavedAt     *time.Time     `json:"saved_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+var draftStore = newRecordStore(draftsFile, "Draft", "drafts", func(d DraftPlan) string { return d.ID })
+
+// draftResource checks a resource sent for a draft: the type must be
+// supported and the spec must decode. The name is derived when missing.
+func draftResource(r PlanResource) (PlanResource, error) {
+	spec, err := decodeResourceSpec(r)
+	if err != nil {
+		return r, err
+	}
+	if r.Name == "" {
+		r.Name = terraformName(spec.identity())
+	}
+	if r.Name == "" {
+		return r, fmt.Errorf("name is required")
+	}
+	if !hclIdentifier.MatchString(r.Name) {
+		return r, fmt.Errorf("invalid name: %q", r.Name)
+	}
+	return r, nil
+}
+
+func (r PlanResource) address() string { return r.Type + "." + r.Name }
+
+// configsReferencing lists the config files whose plan_path is planPath.
+func configsReferencing(planPath string) []string {
+	configs := []string{}
+	paths, _ := filepath.Glob(filepath.Join(workDir(), "config", "*.y*ml"))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			value, ok := strings.CutPrefix(strings.TrimSpace(line), "plan_path:")
+			if ok && filepath.Clean(strings.Trim(strings.TrimSpace(value), `"'`)) == filepath.Clean(planPath) {
+				configs = append(configs, filepath.Join("config", filepath.Base(p)))
+				break
+			}
+		}
+	}
+	return configs
+}
+
+// GET/POST /api/drafts — List draft plans or create one.
+func handleDrafts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		drafts, err := draftStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load drafts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"drafts": drafts})
+	case http.MethodPost:
+		var d DraftPlan
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(d.Name) == "" {
+			jsonError(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		resources := []PlanResource{}
+		for i, res := range d.Resources {
+			res, err := draftResource(res)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("resources[%d]: %v", i, err), http.StatusBadRequest)
+				return
+			}
+			if slices.ContainsFunc(resources, func(o PlanResource) bool { return o.address() == res.address() }) {
+				jsonError(w, fmt.Sprintf("resources[%d]: duplicate address %s", i, res.address()), http.StatusBadRequest)
+				return
+			}
+			resources = append(resources, res)
+		}
+		now := time.Now().UTC()
+		d.ID = fmt.Sprintf("draft-%d", now.UnixNano())
+		d.Resources, d.CreatedAt, d.UpdatedAt = resources, now, now
+		d.SavedPath, d.SavedAt = "", nil
+
+		draftStore.add(w, d, d)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/drafts/{id} — Read a draft, rename it or remove it.
+// Removing a draft keeps its saved plan file.
+func handleDraft(w http.ResponseWriter, r *http.Request) {
+	draftStore.withRecord(w, r, func(drafts []DraftPlan, idx int) ([]DraftPlan, interface{}, int) {
+		switch r.Method {
+		case http.MethodGet:
+			out := map[string]interface{}{"draft": drafts[idx], "configs": []string{}}
+			if drafts[idx].SavedPath != "" {
+				out["configs"] = configsReferencing(drafts[idx].SavedPath)
+			}
+			return nil, out, http.StatusOK
+		case http.MethodPut:
+			var req struct {
+				Name        *string `json:"name"`
+				Description *string `json:"description"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return nil, nil, 0
+			}
+			if req.Name != nil {
+				if strings.TrimSpace(*req.Name) == "" {
+					jsonError(w, "name must not be empty", http.StatusBadRequest)
+					return nil, nil, 0
+				}
+				drafts[idx].Name = *req.Name
+			}
+			if req.Description != nil {
+				drafts[idx].Description = *req.Description
+			}
+			drafts[idx].UpdatedAt = time.Now().UTC()
+			return drafts, drafts[idx], http.StatusOK
+		case http.MethodDelete:
+			return slices.Delete(drafts, idx, idx+1), map[string]string{"status": "ok"}, http.StatusOK
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return nil, nil, 0
+		}
+	})
+}
+
+// POST /api/drafts/{id}/resources — Add a resource to a draft.
+func handleDraftResources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var res PlanResource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := draftResource(res)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	draftStore.withRecord(w, r, func(drafts []DraftPlan, idx int) ([]DraftPlan, interface{}, int) {
+		d := &drafts[idx]
+		if slices.ContainsFunc(d.Resources, func(o PlanResource) bool { return o.address() == res.address() }) {
+			jsonError(w, "Resource already in draft: "+res.address(), http.StatusConflict)
+			return nil, nil, 0
+		}
+		d.Resources = append(d.Resources, res)
+		d.UpdatedAt = time.Now().UTC()
+		return drafts, *d, http.StatusCreated
+	})
+}
+
+// PUT/DELETE /api/drafts/{id}/resources/{address} — Replace or remove a
+// resource of a draft. A PUT may rename the resource but not change its type.
+func handleDraftResource(w http.ResponseWriter, r *http.Request) {
+	address := r.PathValue("address")
+	var res PlanResource
+	if r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	draftStore.withRecord(w, r, func(drafts []DraftPlan, idx int) ([]DraftPlan, interface{}, int) {
+		d := &drafts[idx]
+		ri := slices.IndexFunc(d.Resources, func(o PlanResource) bool { return o.address() == address })
+		if ri < 0 {
+			jsonError(w, "Resource not in draft: "+address, http.StatusNotFound)
+			return nil, nil, 0
+		}
+		if r.Method == http.MethodDelete {
+			d.Resources = slices.Delete(d.Resources, ri, ri+1)
+			d.UpdatedAt = time.Now().UTC()
+			return drafts, *d, http.StatusOK
+		}
+
+		if res.Type == "" {
+			res.Type = d.Resources[ri].Type
+		}
+		if res.Type != d.Resources[ri].Type {
+			jsonError(w, "type cannot be changed; remove the resource and add a new one", http.StatusBadRequest)
+			return nil, nil, 0
+		}
+		if res.Name == "" {
+			res.Name = d.Resources[ri].Name
+		}
+		res, err := draftResource(res)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return nil, nil, 0
+		}
+		if res.address() != address && slices.ContainsFunc(d.Resources, func(o PlanResource) bool { return o.address() == res.address() }) {
+			jsonError(w, "Resource already in draft: "+res.address(), http.StatusConflict)
+			return nil, nil, 0
+		}
+		d.Resources[ri] = res
+		d.UpdatedAt = time.Now().UTC()
+		return drafts, *d, http.StatusOK
+	})
+}
+
+// POST /api/drafts/{id}/validate — Check every resource of a draft.
+func handleDraftValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	draftStore.withRecord(w, r, func(drafts []DraftPlan, idx int) ([]DraftPlan, interface{}, int) {
+		_, problems := checkPlanResources(drafts[idx].Resources)
+		return nil, map[string]interface{}{
+			"valid":     len(problems) == 0,
+			"errors":    problems,
+			"resources": len(drafts[idx].Resources),
+		}, http.StatusOK
+	})
+}
+
+// POST /api/drafts/{id}/save — Build a draft's plan and write it to
+// examples/drafts/<name>.json, optionally pointing a config at it.
+func handleDraftSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Name             string `json:"name"`
+		Config           string `json:"config"`
+		TerraformVersion string `json:"terraform_version"`
+		Overwrite        bool   `json:"overwrite"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	draftStore.withRecord(w, r, func(drafts []DraftPlan, idx int) ([]DraftPlan, interface{}, int) {
+		d := &drafts[idx]
+		name := strings.TrimSuffix(req.Name, ".json")
+		if name == "" && d.SavedPath != "" {
+			name = strings.TrimSuffix(filepath.Base(d.SavedPath), ".json")
+		}
+		if name == "" {
+			name = terraformName(strings.ToLower(d.Name))
+		}
+		if !validPlanName(name) {
+			jsonError(w, "name must contain only letters, digits, '-' and '_'", http.StatusBadRequest)
+			return nil, nil, 0
+		}
+		if req.Config != "" {
+			if !isConfigFile(req.Config) {
+				jsonError(w, "config must be a .yml or .yaml file in config/", http.StatusBadRequest)
+				return nil, nil, 0
+			}
+			fullConfigPath, err := safePath(req.Config)
+			if err == nil {
+				_, err = os.Stat(fullConfigPath)
+			}
+			if err != nil {
+				jsonError(w, "Config not found: "+req.Config, http.StatusBadRequest)
+				return nil, nil, 0
+			}
+		}
+		planPath := draftPlanDir + "/" + name + ".json"
+		owner := slices.IndexFunc(drafts, func(o DraftPlan) bool { return o.SavedPath == planPath })
+		if owner >= 0 && owner != idx && !req.Overwrite {
+			jsonError(w, fmt.Sprintf("%s is the saved plan of draft %s; set overwrite to replace it", planPath, drafts[owner].ID), http.StatusConflict)
+			return nil, nil, 0
+		}
+		fullPlanPath, err := safePath(planPath)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return nil, nil, 0
+		}
+		if _, err := os.Stat(fullPlanPath); err == nil && owner < 0 && !req.Overwrite {
+			jsonError(w, planPath+" already exists; set overwrite to replace it", http.StatusConflict)
+			return nil, nil, 0
+		}
+		if _, problems := checkPlanResources(d.Resources); len(problems) > 0 {
+			return nil, map[string]interface{}{"error": "Draft does not validate", "errors": problems},
+				http.StatusUnprocessableEntity
+		}
+		plan, err := buildPlan(d.Resources, req.TerraformVersion)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+			return nil, nil, 0
+		}
+		planBytes, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			jsonError(w, "Failed to marshal plan: "+err.Error(), http.StatusInternalServerError)
+			return nil, nil, 0
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fullPlanPath), 0755); err != nil {
+			jsonError(w, "Failed to create drafts directory: "+err.Error(), http.StatusInternalServerError)
+			return nil, nil, 0
+		}
+		if _, err := writePlanFile(fullPlanPath, planPath, planBytes); err != nil {
+			jsonError(w, "Failed to write plan: "+err.Error(), http.StatusInternalServerError)
+			return nil, nil, 0
+		}
+		// The plan is on disk now, so the draft records it even if the
+		// config cannot be pointed at it.
+		if owner >= 0 && owner != idx {
+			drafts[owner].SavedPath, drafts[owner].SavedAt = "", nil
+		}
+		now := time.Now().UTC()
+		d.SavedPath, d.SavedAt = planPath, &now
+		if req.Config != "" {
+			if err := setConfigPlanPath(req.Config, planPath); err != nil {
+				return drafts, map[string]interface{}{
+					"error":     "Plan saved but config not updated: " + err.Error(),
+					"plan_path": planPath,
+				}, http.StatusInternalServerError
+			}
+		}
+		return drafts, map[string]interface{}{
+			"status":    "ok",
+			"plan_path": planPath,
+			"configs":   configsReferencing(planPath),
+		}, http.StatusOK
+	})
+}
+
+// validPlanName reports whether s can name a saved draft plan: letters,
+// digits, '-' and '_', so the file is always <name>.json.
+func validPlanName(s string) bool {
+	if s == "" || len(s) > 100 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// isConfigFile reports whether p is a .yml or .yaml file directly in the
+// config directory, the only files a draft may point at its plan.
+func isConfigFile(p string) bool {
+	clean := filepath.Clean(p)
+	ext := filepath.Ext(clean)
+	return filepath.Dir(clean) == "config" && (ext == ".yml" || ext == ".yaml")
+}
+
+// ---------------------------------------------------------------------------
+// HCL export
+// ---------------------------------------------------------------------------
+
+// HCLResource is the HCL rendered for one planned resource.
+type HCLResource struct {
+	Address string   `json:"address"`
+	HCL     string   `json:"hcl"`
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// hclExport turns the after values of a plan's resource changes into
+// resource blocks. Sensitive values become references to variables, which
+// are declared in Variables.
+type hclExport struct {
+	Resources []HCLResource
+	Variables []string
+}
+
+// exportPlanHCL renders the resources of a plan that exist after apply.
+// Data sources and deleted resources are skipped. An address filter keeps a
+// single resource, and its instances if it uses count or for_each.
+func exportPlanHCL(plan map[string]interface{}, address string) *hclExport {
+	export := &hclExport{Resources: []HCLResource{}, Variables: []string{}}
+	changes, _ := plan["resource_changes"].([]interface{})
+	for _, c := range changes {
+		rc, _ := c.(map[string]interface{})
+		change, _ := rc["change"].(map[string]interface{})
+		after, _ := change["after"].(map[string]interface{})
+		addr, _ := rc["address"].(string)
+		resType, _ := rc["type"].(string)
+		name, _ := rc["name"].(string)
+		if after == nil || rc["mode"] == "data" || resType == "" || name == "" {
+			continue
+		}
+		if address != "" && addr != address && strings.TrimSuffix(addr, instanceKey(rc)) != address {
+			continue
+		}
+		if key := instanceKey(rc); key != "" {
+			name = terraformName(name + "_" + strings.Trim(key, `[]"`))
+		}
+
+		res := HCLResource{Address: addr}
+		var b strings.Builder
+		if module, _ := rc["module_address"].(string); module != "" {
+			fmt.Fprintf(&b, "# %s\n", addr)
+		}
+		fmt.Fprintf(&b, "resource %q %q {\n", resType, name)
+		export.writeBody(&b, &res, after, change["after_unknown"], change["after_sensitive"], 1, resType, name, "", false)
+		b.WriteString("}\n")
+		res.HCL = b.String()
+		export.Resources = append(export.Resources, res)
+	}
+	return export
+}
+
+// instanceKey is the "[0]" or `["key"]` suffix of a counted resource's
+// address, or "".
+func instanceKey(rc map[string]interface{}) string {
+	switch k := rc["index"].(type) {
+	case float64:
+		return fmt.Sprintf("[%d]", int(k))
+	case string:
+		return fmt.Sprintf("[%q]", k)
+	}
+	return ""
+}
+
+// text joins the variables and resources into one HCL document.
+func (e *hclExport) text() string {
+	var parts []string
+	for _, v := range e.Variables {
+		parts = append(parts, fmt.Sprintf("variable %q {\n  type      = string\n  sensitive = true\n}\n", v))
+	}
+	for _, r := range e.Resources {
+		parts = append(parts, r.HCL)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// writeBody writes the attributes, then the nested blocks, of one block.
+// unknown and sensitive are the matching parts of after_unknown and
+// after_sensitive. In `terraform show -json` nested blocks are always lists
+// of objects, so a list of objects becomes one block per item and an object
+// becomes a map attribute. With object set, the body is the inside of such
+// a map: it has no blocks, and empty values are kept.
+func (e *hclExport) writeBody(b *strings.Builder, res *HCLResource, values map[string]interface{}, unknown, sensitive interface{}, depth int, resType, name, path string, object bool) {
+	indent := strings.Repeat("  ", depth)
+	type attr struct{ key, value string }
+	var attrs []attr
+	var blocks []string
+
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		v, attrPath := values[k], strings.TrimPrefix(path+"."+k, ".")
+		if hclChild(unknown, k) == true || (depth == 1 && (computedAttributes[k] || slices.Contains(planComputedAttributes[resType], k))) {
+			res.Dropped = append(res.Dropped, attrPath)
+			continue
+		}
+		if hclEmpty(v) && !object {
+			continue
+		}
+		if hclChild(sensitive, k) == true {
+			variable := terraformName(name + "_" + strings.ReplaceAll(attrPath, ".", "_"))
+			e.Variables = append(e.Variables, variable)
+			attrs = append(attrs, attr{hclKey(k), "var." + variable})
+			continue
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if len(val) > 0 {
+				var body strings.Builder
+				e.writeBody(&body, res, val, hclChild(unknown, k), hclChild(sensitive, k), depth+1, resType, name, attrPath, true)
+				if body.Len() > 0 {
+					attrs = append(attrs, attr{hclKey(k), "{\n" + body.String() + indent + "}"})
+				}
+				continue
+			}
+		case []interface{}:
+			if !object && hclObjectList(val) {
+				for i, item := range val {
+					blocks = append(blocks, e.block(res, k, item.(map[string]interface{}),
+						hclIndex(hclChild(unknown, k), i), hclIndex(hclChild(sensitive, k), i), depth, resType, name, attrPath))
+				}
+				continue
+			}
+		case string:
+			if doc := strings.TrimSpace(val); strings.HasPrefix(doc, "{") {
+				var obj map[string]interface{}
+				if json.Unmarshal([]byte(doc), &obj) == nil {
+					attrs = append(attrs, attr{hclKey(k), "jsonencode(" + hclValue(obj, depth) + ")"})
+					continue
+				}
+			}
+		}
+		attrs = append(attrs, attr{hclKey(k), hclValue(v, depth)})
+	}
+
+	// Align the "=" of consecutive single-line attributes, as terraform fmt does.
+	for i := 0; i < len(attrs); {
+		j, width := i, 0
+		for j < len(attrs) && !strings.Contains(attrs[j].value, "\n") {
+			width = max(width, len(attrs[j].key))
+			j++
+		}
+		if j == i {
+			fmt.Fprintf(b, "%s%s = %s\n", indent, attrs[i].key, attrs[i].value)
+			i++
+			continue
+		}
+		for ; i < j; i++ {
+			fmt.Fprintf(b, "%s%-*s = %s\n", indent, width, attrs[i].key, attrs[i].value)
+		}
+	}
+	for i, block := range blocks {
+		if len(attrs) > 0 || i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(block)
+	}
+}
+
+// block renders a nested block, or "" if nothing in it is left to set.
+func (e *hclExport) block(res *HCLResource, key string, values map[string]interface{}, unknown, sensitive interface{}, depth int, resType, name, path string) string {
+	var body strings.Builder
+	e.writeBody(&body, res, values, unknown, sensitive, depth+1, resType, name, path, false)
+	indent := strings.Repeat("  ", depth)
+	if body.Len() == 0 {
+		return indent + key + " {}\n"
+	}
+	return indent + key + " {\n" + body.String() + indent + "}\n"
+}
+
+// hclChild returns the part of an after_unknown or after_sensitive value
+// for key. A true value covers everything below it.
+func hclChild(v interface{}, key string) interface{} {
+	if v == true {
+		return true
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		return m[key]
+	}
+	return nil
+}
+
+// hclIndex is hclChild for list elements.
+func hclIndex(v interface{}, i int) interface{} {
+	if v == true {
+		return true
+	}
+	if l, ok := v.([]interface{}); ok && i < len(l) {
+		return l[i]
+	}
+	return nil
+}
+
+// hclObjectList reports whether v is a non-empty list of objects, which
+// Terraform uses for nested blocks.
+func hclObjectList(v []interface{}) bool {
+	for _, item := range v {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(v) > 0
+}
+
+// hclEmpty reports whether v is unset: null, an empty string, list or map.
+func hclEmpty(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []interface{}:
+		return len(val) == 0
+	case map[string]interface{}:
+		return len(val) == 0
+	}
+	return false
+}
+
+// GET /api/files/plan/hcl?path=<plan>&address=<address>&format=json|hcl —
+// Render a plan's resources as Terraform HCL.
+// POST /api/files/plan/hcl — Write the HCL of a plan into the terraform
+// project directory.
+func handlePlanHCL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path      string `json:"path"`
+		Address   string `json:"address"`
+		File      string `json:"file"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Path, req.Address = q.Get("path"), q.Get("address")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.File == "" || filepath.Base(req.File) != req.File || !strings.HasSuffix(req.File, ".tf") {
+			jsonError(w, "file must be a .tf file name", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Path == "" {
+		req.Path = "examples/plan.json"
+	}
+	plan, status, err := readPlanFile(req.Path)
+	if err != nil {
+		jsonError(w, err.Error(), status)
+		return
+	}
+	export := exportPlanHCL(plan, req.Address)
+	if req.Address != "" && len(export.Resources) == 0 {
+		jsonError(w, "Resource not in plan: "+req.Address, http.StatusNotFound)
+		return
+	}
+	text := export.text()
+
+	if r.Method == http.MethodPost {
+		tfDir := filepath.Join(workDir(), "terraform")
+		target := filepath.Join(tfDir, req.File)
+		if _, err := os.Stat(target); err == nil && !req.Overwrite {
+			jsonError(w, "File already exists: "+req.File, http.StatusConflict)
+			return
+		}
+		if err := os.MkdirAll(tfDir, 0755); err != nil {
+			jsonError(w, "Failed to create terraform directory: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := os.WriteFile(target, []byte(text), 0644); err != nil {
+			jsonError(w, "Failed to write file: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if r.URL.Query().Get("format") == "hcl" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(text))
+		return
+	}
+
+	resp := map[string]interface{}{
+		"path":      req.Path,
+		"resources": export.Resources,
+		"variables": export.Variables,
+		"hcl":       text,
+	}
+	if r.Method == http.MethodPost {
+		resp["file"] = "terraform/" + req.File
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ---------------------------------------------------------------------------
+// Plan summary
+// ---------------------------------------------------------------------------
+
+// readPlanFile loads a plan JSON file relative to the working directory. The
+// returned status is the HTTP code to report the error with.
+func readPlanFile(rel string) (map[string]interface{}, int, error) {
+	fullPath, err := safePath(rel)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, http.StatusNotFound, fmt.Errorf("plan not found: %s", rel)
+	}
+	var plan map[string]interface{}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("plan is not valid JSON: %v", err)
+	}
+	return plan, 0, nil
+}
+
+// planAction collapses the actions of a resource change into one of create,
+// update, delete, replace, read or no-op.
+func planAction(actions []interface{}) string {
+	switch len(actions) {
+	case 0:
+		return "no-op"
+	case 1:
+		action, _ := actions[0].(string)
+		return action
+	}
+	return "replace"
+}
+
+// PlanTypeGroup counts the resources of one type.
+type PlanTypeGroup struct {
+	Type      string         `json:"type"`
+	Provider  string         `json:"provider"`
+	Count     int            `json:"count"`
+	Actions   map[string]int `json:"actions"`
+	Addresses []string       `json:"addresses"`
+}
+
+// PlanProviderGroup counts the resources of one provider.
+type PlanProviderGroup struct {
+	Provider string   `json:"provider"`
+	Count    int      `json:"count"`
+	Types    []string `json:"types"`
+}
+
+// PlanSensitiveValue is an attribute marked sensitive in a resource change.
+type PlanSensitiveValue struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+}
+
+// PlanModule is a module call in the module tree. The root module has an
+// empty address. Instances lists the addresses of count and for_each
+// instances, and Resources counts the changes across all of them.
+type PlanModule struct {
+	Address   string        `json:"address"`
+	Source    string        `json:"source,omitempty"`
+	Instances []string      `json:"instances,omitempty"`
+	Resources int           `json:"resources"`
+	Children  []*PlanModule `json:"children"`
+}
+
+// PlanSummary describes a plan without running a scan on it.
+type PlanSummary struct {
+	Path             string               `json:"path"`
+	FormatVersion    string               `json:"format_version"`
+	TerraformVersion string               `json:"terraform_version"`
+	Total            int                  `json:"total"`
+	Actions          map[string]int       `json:"actions"`
+	Types            []PlanTypeGroup      `json:"types"`
+	Providers        []PlanProviderGroup  `json:"providers"`
+	Addresses        []string             `json:"addresses"`
+	Sensitive        []PlanSensitiveValue `json:"sensitive"`
+	Modules          *PlanModule          `json:"modules"`
+}
+
+// summarizePlan counts the resource changes of a plan. Data source reads
+// are left out, as they change nothing.
+func summarizePlan(path string, plan map[string]interface{}) *PlanSummary {
+	s := &PlanSummary{
+		Path:      path,
+		Actions:   map[string]int{"create": 0, "update": 0, "delete": 0, "replace": 0, "no-op": 0},
+		Types:     []PlanTypeGroup{},
+		Providers: []PlanProviderGroup{},
+		Addresses: []string{},
+		Sensitive: []PlanSensitiveValue{},
+	}
+	s.FormatVersion, _ = plan["format_version"].(string)
+	s.TerraformVersion, _ = plan["terraform_version"].(string)
+
+	types := map[string]*PlanTypeGroup{}
+	providers := map[string]*PlanProviderGroup{}
+	modules := map[string]int{}
+	changes, _ := plan["resource_changes"].([]interface{})
+	for _, c := range changes {
+		rc, _ := c.(map[string]interface{})
+		if rc == nil || rc["mode"] == "data" {
+			continue
+		}
+		address, _ := rc["address"].(string)
+		resType, _ := rc["type"].(string)
+		provider, _ := rc["provider_name"].(string)
+		module, _ := rc["module_address"].(string)
+		change, _ := rc["change"].(map[string]interface{})
+		actions, _ := change["actions"].([]interface{})
+		action := planAction(actions)
+
+		s.Total++
+		s.Actions[action]++
+		s.Addresses = append(s.Addresses, address)
+		modules[module]++
+
+		group := types[resType]
+		if group == nil {
+			group = &PlanTypeGroup{Type: resType, Provider: provider, Actions: map[string]int{}}
+			types[resType] = group
+		}
+		group.Count++
+		group.Actions[action]++
+		group.Addresses = append(group.Addresses, address)
+
+		p := providers[provider]
+		if p == nil {
+			p = &PlanProviderGroup{Provider: provider}
+			providers[provider] = p
+		}
+		p.Count++
+		if !slices.Contains(p.Types, resType) {
+			p.Types = append(p.Types, resType)
+		}
+
+		var paths []string
+		for _, key := range []string{"before_sensitive", "after_sensitive"} {
+			for _, p := range markedPaths(change[key], "") {
+				if !slices.Contains(paths, p) {
+					paths = append(paths, p)
+				}
+			}
+		}
+		slices.Sort(paths)
+		for _, p := range paths {
+			s.Sensitive = append(s.Sensitive, PlanSensitiveValue{Address: address, Path: p})
+		}
+	}
+
+	for _, t := range slices.Sorted(maps.Keys(types)) {
+		s.Types = append(s.Types, *types[t])
+	}
+	for _, p := range slices.Sorted(maps.Keys(providers)) {
+		slices.Sort(providers[p].Types)
+		s.Providers = append(s.Providers, *providers[p])
+	}
+	slices.Sort(s.Addresses)
+	s.Modules = planModuleTree(plan, modules)
+	return s
+}
+
+// markedPaths lists the paths set to true in an after_unknown or
+// after_sensitive value, such as "password" or "environment[0].variables".
+func markedPaths(v interface{}, path string) []string {
+	var paths []string
+	switch val := v.(type) {
+	case bool:
+		if val && path != "" {
+			paths = append(paths, path)
+		}
+	case map[string]interface{}:
+		for _, k := range slices.Sorted(maps.Keys(val)) {
+			paths = append(paths, markedPaths(val[k], strings.TrimPrefix(path+"."+k, "."))...)
+		}
+	case []interface{}:
+		for i, item := range val {
+			paths = append(paths, markedPaths(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return paths
+}
+
+// planModuleTree builds the module tree from the module calls in the plan's
+// configuration, so modules without changes are listed too. Modules that
+// only show up in resource changes are added under their parent. modules
+// holds the number of resource changes per module instance address.
+func planModuleTree(plan map[string]interface{}, modules map[string]int) *PlanModule {
+	root := &PlanModule{Children: []*PlanModule{}}
+	nodes := map[string]*PlanModule{"": root}
+
+	var walk func(parent *PlanModule, module map[string]interface{})
+	walk = func(parent *PlanModule, module map[string]interface{}) {
+		calls, _ := module["module_calls"].(map[string]interface{})
+		for _, name := range slices.Sorted(maps.Keys(calls)) {
+			call, _ := calls[name].(map[string]interface{})
+			child := &PlanModule{Address: strings.TrimPrefix(parent.Address+".module."+name, "."), Children: []*PlanModule{}}
+			child.Source, _ = call["source"].(string)
+			nodes[child.Address] = child
+			parent.Children = append(parent.Children, child)
+			inner, _ := call["module"].(map[string]interface{})
+			walk(child, inner)
+		}
+	}
+	config, _ := plan["configuration"].(map[string]interface{})
+	rootModule, _ := config["root_module"].(map[string]interface{})
+	walk(root, rootModule)
+
+	var node func(address string) *PlanModule
+	node = func(address string) *PlanModule {
+		if n := nodes[address]; n != nil {
+			return n
+		}
+		parent := ""
+		if i := strings.LastIndex(address, ".module."); i >= 0 {
+			parent = address[:i]
+		}
+		n := &PlanModule{Address: address, Children: []*PlanModule{}}
+		nodes[address] = n
+		p := node(parent)
+		p.Children = append(p.Children, n)
+		return n
+	}
+	for _, address := range slices.Sorted(maps.Keys(modules)) {
+		n := node(moduleCallAddress(address))
+		n.Resources += modules[address]
+		if address != n.Address {
+			n.Instances = append(n.Instances, address)
+		}
+	}
+	return root
+}
+
+var moduleInstanceKey = regexp.MustCompile(`\[[^\]]*\]`)
+
+// moduleCallAddress strips the instance keys from a module address, so
+// module.a["x"].module.b becomes module.a.module.b.
+func moduleCallAddress(address string) string {
+	return moduleInstanceKey.ReplaceAllString(address, "")
+}
+
+// GET /api/files/plan/summary?path=<plan> — Count a plan's resource changes
+// by action, type and provider, and list its modules and sensitive values.
+func handlePlanSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	planPath := r.URL.Query().Get("path")
+	if planPath == "" {
+		planPath = "examples/plan.json"
+	}
+	plan, status, err := readPlanFile(planPath)
+	if err != nil {
+		jsonError(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summarizePlan(planPath, plan))
+}
+
+// ---------------------------------------------------------------------------
+// Plan diff
+// ---------------------------------------------------------------------------
+
+// planTimestampAttributes are top-level attributes the AWS provider
+// computes as timestamps. They change on every plan and are left out of
+// plan diffs. Timestamps set in configuration, such as a lifecycle rule's
+// expiration date, are compared like any other value.
+var planTimestampAttributes = map[string][]string{
+	"aws_acm_certificate":          {"not_after", "not_before"},
+	"aws_db_instance":              {"latest_restorable_time"},
+	"aws_iam_access_key":           {"create_date"},
+	"aws_iam_role":                 {"create_date"},
+	"aws_iam_service_linked_role":  {"create_date"},
+	"aws_lambda_function":          {"last_modified"},
+	"aws_lambda_layer_version":     {"created_date"},
+	"aws_secretsmanager_secret":    {"last_changed_date"},
+	"aws_ssm_parameter":            {"last_modified_date"},
+	"aws_cloudformation_stack_set": {"creation_time"},
+}
+
+// planSetAttributes are top-level attributes the AWS provider models as
+// sets. Their order in a plan carries no meaning, so plan diffs compare
+// their items sorted by content.
+var planSetAttributes = map[string]bool{
+	"ingress": true, "egress": true, "vpc_security_group_ids": true, "security_groups": true,
+	"ebs_block_device": true, "ephemeral_block_device": true, "network_interface": true,
+}
+
+// PlanResourceRef is a resource added to or removed from a plan.
+type PlanResourceRef struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Action  string `json:"action"`
+}
+
+// PlanAttributeChange is an attribute whose planned value differs between
+// two plans. From or To is null when the attribute is only set in one.
+type PlanAttributeChange struct {
+	Path      string      `json:"path"`
+	From      interface{} `json:"from"`
+	To        interface{} `json:"to"`
+	Sensitive bool        `json:"sensitive,omitempty"`
+}
+
+// PlanResourceDiff is a resource in both plans whose action or planned
+// values differ.
+type PlanResourceDiff struct {
+	Address    string                `json:"address"`
+	Type       string                `json:"type"`
+	FromAction string                `json:"from_action"`
+	ToAction   string                `json:"to_action"`
+	Attributes []PlanAttributeChange `json:"attributes"`
+}
+
+// PlanDiff compares the resource changes of two plans by address.
+type PlanDiff struct {
+	From      string             `json:"from"`
+	To        string             `json:"to"`
+	Added     []PlanResourceRef  `json:"added"`
+	Removed   []PlanResourceRef  `json:"removed"`
+	Changed   []PlanResourceDiff `json:"changed"`
+	Unchanged int                `json:"unchanged"`
+	Ignored   int                `json:"ignored"`
+}
+
+// planChanges indexes the managed resource changes of a plan by address.
+func planChanges(plan map[string]interface{}) map[string]map[string]interface{} {
+	byAddress := map[string]map[string]interface{}{}
+	changes, _ := plan["resource_changes"].([]interface{})
+	for _, c := range changes {
+		rc, _ := c.(map[string]interface{})
+		address, _ := rc["address"].(string)
+		if address == "" || rc["mode"] == "data" {
+			continue
+		}
+		byAddress[address] = rc
+	}
+	return byAddress
+}
+
+// diffPlans compares two plans. Attributes that are unknown in either plan
+// and computed timestamps are not compared; their count is reported as
+// Ignored.
+func diffPlans(fromPath, toPath string, from, to map[string]interface{}) *PlanDiff {
+	d := &PlanDiff{From: fromPath, To: toPath, Added: []PlanResourceRef{}, Removed: []PlanResourceRef{}, Changed: []PlanResourceDiff{}}
+	before, after := planChanges(from), planChanges(to)
+	ref := func(rc map[string]interface{}) PlanResourceRef {
+		change, _ := rc["change"].(map[string]interface{})
+		actions, _ := change["actions"].([]interface{})
+		r := PlanResourceRef{Action: planAction(actions)}
+		r.Address, _ = rc["address"].(string)
+		r.Type, _ = rc["type"].(string)
+		return r
+	}
+
+	for _, address := range slices.Sorted(maps.Keys(after)) {
+		if before[address] == nil {
+			d.Added = append(d.Added, ref(after[address]))
+		}
+	}
+	for _, address := range slices.Sorted(maps.Keys(before)) {
+		if after[address] == nil {
+			d.Removed = append(d.Removed, ref(before[address]))
+			continue
+		}
+		oldRef, newRef := ref(before[address]), ref(after[address])
+		attrs, ignored := diffPlannedValues(before[address], after[address])
+		d.Ignored += ignored
+		if oldRef.Action == newRef.Action && len(attrs) == 0 {
+			d.Unchanged++
+			continue
+		}
+		d.Changed = append(d.Changed, PlanResourceDiff{
+			Address: address, Type: newRef.Type,
+			FromAction: oldRef.Action, ToAction: newRef.Action,
+			Attributes: attrs,
+		})
+	}
+	return d
+}
+
+// diffPlannedValues compares the change.after values of one resource in two
+// plans, attribute by attribute. Sensitive values are compared but not
+// returned.
+func diffPlannedValues(from, to map[string]interface{}) ([]PlanAttributeChange, int) {
+	fromChange, _ := from["change"].(map[string]interface{})
+	toChange, _ := to["change"].(map[string]interface{})
+	fromChange, toChange = sortPlanSets(fromChange), sortPlanSets(toChange)
+	resType, _ := to["type"].(string)
+	oldValues, newValues := map[string]interface{}{}, map[string]interface{}{}
+	flattenPlanValue(fromChange["after"], "", oldValues)
+	flattenPlanValue(toChange["after"], "", newValues)
+	unknown := append(markedPaths(fromChange["after_unknown"], ""), markedPaths(toChange["after_unknown"], "")...)
+	sensitive := append(markedPaths(fromChange["after_sensitive"], ""), markedPaths(toChange["after_sensitive"], "")...)
+
+	paths := slices.Collect(maps.Keys(oldValues))
+	for p := range newValues {
+		if _, ok := oldValues[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	changes := []PlanAttributeChange{}
+	ignored := 0
+	for _, p := range paths {
+		oldValue, newValue := oldValues[p], newValues[p]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if pathCovered(p, unknown) || pathCovered(p, planTimestampAttributes[resType]) {
+			ignored++
+			continue
+		}
+		c := PlanAttributeChange{Path: p, From: oldValue, To: newValue}
+		if pathCovered(p, sensitive) {
+			c.From, c.To, c.Sensitive = nil, nil, true
+		}
+		changes = append(changes, c)
+	}
+	return changes, ignored
+}
+
+// flattenPlanValue collects the leaf values of v by path, such as
+// "tags.Name" or "ingress[0].from_port". Empty maps and lists are leaves.
+func flattenPlanValue(v interface{}, path string, out map[string]interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) > 0 {
+			for k, item := range val {
+				flattenPlanValue(item, strings.TrimPrefix(path+"."+k, "."), out)
+			}
+			return
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			for i, item := range val {
+				flattenPlanValue(item, fmt.Sprintf("%s[%d]", path, i), out)
+			}
+			return
+		}
+	}
+	if path != "" {
+		out[path] = v
+	}
+}
+
+// pathCovered reports whether path, or an attribute containing it, is in
+// paths.
+func pathCovered(path string, paths []string) bool {
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// sortPlanSets returns a copy of a resource change whose set attributes
+// (planSetAttributes) list their items sorted by content. The matching
+// after_unknown and after_sensitive entries are reordered with them, so
+// paths such as ingress[1].from_port keep pointing at the same item.
+func sortPlanSets(change map[string]interface{}) map[string]interface{} {
+	after, _ := change["after"].(map[string]interface{})
+	if after == nil {
+		return change
+	}
+	sorted := maps.Clone(change)
+	marks := map[string]map[string]interface{}{}
+	for _, field := range []string{"after", "after_unknown", "after_sensitive"} {
+		if m, ok := change[field].(map[string]interface{}); ok {
+			marks[field] = maps.Clone(m)
+			sorted[field] = marks[field]
+		}
+	}
+	for k, v := range after {
+		items, ok := v.([]interface{})
+		if !planSetAttributes[k] || !ok || len(items) < 2 {
+			continue
+		}
+		keys := make([]string, len(items))
+		for i, item := range items {
+			data, _ := json.Marshal(item)
+			keys[i] = string(data)
+		}
+		order := make([]int, len(items))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int { return strings.Compare(keys[a], keys[b]) })
+		for _, m := range marks {
+			list, ok := m[k].([]interface{})
+			if !ok || len(list) != len(items) {
+				continue
+			}
+			reordered := make([]interface{}, len(list))
+			for i, j := range order {
+				reordered[i] = list[j]
+			}
+			m[k] = reordered
+		}
+	}
+	return sorted
+}
+
+// GET /api/files/plan/diff?from=<plan>&to=<plan> — Compare two plan files
+// in the examples directory by resource address.
+func handlePlanDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	fromPath, toPath := q.Get("from"), q.Get("to")
+	if fromPath == "" || toPath == "" {
+		jsonError(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+	var plans []map[string]interface{}
+	for _, p := range []string{fromPath, toPath} {
+		if !strings.HasPrefix(filepath.Clean(p), "examples"+string(filepath.Separator)) {
+			jsonError(w, "Plan must be in the examples directory: "+p, http.StatusBadRequest)
+			return
+		}
+		plan, status, err := readPlanFile(p)
+		if err != nil {
+			jsonError(w, err.Error(), status)
+			return
+		}
+		plans = append(plans, plan)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diffPlans(fromPath, toPath, plans[0], plans[1]))
+}
+
+// ---------------------------------------------------------------------------
+// Terraform endpoints
+// ---------------------------------------------------------------------------
+
+func terraformPath() string {
+	if p := os.Getenv("TERRAFORM_PATH"); p != "" {
+		return p
+	}
+	return "terraform"
+}
+
+func terraformVersion() (string, error) {
+	output, err := exec.Command(terraformPath(), "version", "-json").Output()
+	if err != nil {
+		return "", err
+	}
+	var vInfo map[string]interface{}
+	if json.Unmarshal(output, &vInfo) != nil {
+		return "", nil
+	}
+	version, _ := vInfo["terraform_version"].(string)
+	return version, nil
+}
+
+// GET /api/terraform/status — Check Terraform availability and list .tf files.
+func handleTerraformStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version, versionErr := terraformVersion()
+	available := versionErr == nil
+
+	tfDir := filepath.Join(workDir(), "terraform")
+	tfFiles := []string{}
+	if entries, err := os.ReadDir(tfDir); err == nil {
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".tf") {
+				tfFiles = append(tfFiles, e.Name())
+			}
+		}
+	}
+
+	_, initErr := os.Stat(filepath.Join(tfDir, ".terraform"))
+	initialized := initErr == nil
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"available":   available,
+		"version":     version,
+		"tf_files":    tfFiles,
+		"has_files":   len(tfFiles) > 0,
+		"initialized": initialized,
+		"tf_dir":      "terraform/",
+	})
+}
+
+// POST /api/terraform/upload — Upload .tf and .tfvars files.
+func handleTerraformUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 50*1024*1024)
+	if err := r.ParseMultipartForm(50 << 20); err != nil {
+		jsonError(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+
+	tfDir := filepath.Join(workDir(), "terraform")
+	os.MkdirAll(tfDir, 0755)
+
+	uploaded := []string{}
+	for _, fileHeaders := range r.MultipartForm.File {
+		for _, fh := range fileHeaders {
+			if !strings.HasSuffix(fh.Filename, ".tf") && !strings.HasSuffix(fh.Filename, ".tfvars") {
+				jsonError(w, "Only .tf and .tfvars files allowed: "+fh.Filename, http.StatusBadRequest)
+				return
+			}
+			safeName := filepath.Base(fh.Filename)
+			file, err := fh.Open()
+			if err != nil {
+				jsonError(w, "Failed to read file: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data, readErr := io.ReadAll(file)
+			file.Close()
+			if readErr != nil {
+				jsonError(w, "Failed to read file data: "+readErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err := os.WriteFile(filepath.Join(tfDir, safeName), data, 0644); err != nil {
+				jsonError(w, "Failed to save file: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			uploaded = append(uploaded, safeName)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "ok",
+		"uploaded": uploaded,
+		"tf_dir":   "terraform/",
+	})
+}
+
+// POST /api/terraform/plan — Start async terraform plan generation.
+func handleTerraformPlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !tfMutex.TryLock() {
+		jsonError(w, "Another Terraform operation is already running", http.StatusConflict)
+		return
+	}
+
+	tfDir := filepath.Join(workDir(), "terraform")
+	entries, err := os.ReadDir(tfDir)
+	if err != nil {
+		tfMutex.Unlock()
+		jsonError(w, "Terraform directory not found. Upload .tf files first.", http.StatusBadRequest)
+		return
+	}
+	hasTf := false
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tf") {
+			hasTf = true
+			break
+		}
+	}
+	if !hasTf {
+		tfMutex.Unlock()
+		jsonError(w, "No .tf files found. Upload Terraform files first.", http.StatusBadRequest)
+		return
+	}
+
+	jobID := fmt.Sprintf("tf-%d", time.Now().UnixMilli())
+	job := &TerraformJob{
+		ID:        jobID,
+		Status:    "pending",
+		Phase:     "Starting...",
+		StartedAt: time.Now(),
+	}
+
+	tfJobsMu.Lock()
+	tfJobs[jobID] = job
+	tfJobsMu.Unlock()
+
+	go runTerraformPipeline(job, tfDir)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "started",
+		"job_id": jobID,
+	})
+}
+
+func runTerraformPipeline(job *TerraformJob, tfDir string) {
+	defer tfMutex.Unlock()
+	defer func() {
+		if len(job.Redactions) > 0 {
+			recordRedaction(RedactionRecord{Source: "job", Target: job.ID, Action: "output", Patterns: job.Redactions})
+		}
+		if job.Status == "error" {
+			dispatchEvent(eventTerraformJobFailed, map[string]interface{}{
+				"job_id":     job.ID,
+				"phase":      job.Phase,
+				"error":      job.Error,
+				"started_at": job.StartedAt,
+				"done_at":    job.DoneAt,
+			})
+		}
+	}()
+
+	tf := terraformPath()
+	var outputBuf strings.Builder
+
+	// Step 1: terraform init (10 min timeout — downloads providers)
+	job.Status = "init"
+	job.Phase = "Running terraform init..."
+	initCtx, initCancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer initCancel()
+	initCmd := exec.CommandContext(initCtx, tf, "init", "-no-color", "-input=false")
+	initCmd.Dir = tfDir
+	initOutput, initErr := initCmd.CombinedOutput()
+	outputBuf.Write(initOutput)
+	outputBuf.WriteByte('\n')
+	job.Output, job.Redactions = scrubSecrets(outputBuf.String())
+	if initErr != nil {
+		job.Status = "error"
+		job.Error = "terraform init failed: " + initErr.Error()
+		job.Phase = "Init failed"
+		job.DoneAt = time.Now()
+		return
+	}
+
+	// Step 2: terraform plan (10 min timeout)
+	job.Status = "plan"
+	job.Phase = "Running terraform plan..."
+	planBinaryPath := filepath.Join(tfDir, "tfplan.binary")
+	planCtx, planCancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer planCancel()
+	planCmd := exec.CommandContext(planCtx, tf, "plan", "-out="+planBinaryPath, "-no-color", "-input=false")
+	planCmd.Dir = tfDir
+	planOutput, planErr := planCmd.CombinedOutput()
+	outputBuf.Write(planOutput)
+	outputBuf.WriteByte('\n')
+	job.Output, job.Redactions = scrubSecrets(outputBuf.String())
+	if planErr != nil {
+		job.Status = "error"
+		job.Error = "terraform plan failed: " + planErr.Error()
+		job.Phase = "Plan failed"
+		job.DoneAt = time.Now()
+		return
+	}
+
+	// Step 3: terraform show -json (5 min timeout)
+	job.Status = "show"
+	job.Phase = "Converting plan to JSON..."
+	showCtx, showCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer showCancel()
+	showCmd := exec.CommandContext(showCtx, tf, "show", "-json", "-no-color", planBinaryPath)
+	showCmd.Dir = tfDir
+	showOutput, showErr := showCmd.CombinedOutput()
+	if showErr != nil {
+		job.Status = "error"
+		job.Error = "terraform show failed: " + showErr.Error()
+		job.Phase = "Show failed"
+		job.DoneAt = time.Now()
+		return
+	}
+
+	if !json.Valid(showOutput) {
+		job.Status = "error"
+		job.Error = "terraform show produced invalid JSON"
+		job.Phase = "Invalid output"
+		job.DoneAt = time.Now()
+		return
+	}
+
+	// Step 4: Save plan.json
+	planJsonPath := "examples/terraform-plan.json"
+	fullPlanPath, pathErr := safePath(planJsonPath)
+	if pathErr != nil {
+		job.Status = "error"
+		job.Error = "Path error: " + pathErr.Error()
+		job.DoneAt = time.Now()
+		return
+	}
+	if _, err := writePlanFile(fullPlanPath, planJsonPath, showOutput); err != nil {
+		job.Status = "error"
+		job.Error = "Failed to save plan JSON: " + err.Error()
+		job.DoneAt = time.Now()
+		return
+	}
+
+	// Step 5: Update config
+	configFile := "config/cloudrift-s3.yml"
+	fullConfigPath, _ := safePath(configFile)
+	if configData, readErr := os.ReadFile(fullConfigPath); readErr == nil {
+		lines := strings.Split(string(configData), "\n")
+		updated := false
+		for i, line := range lines {
+			if strings.HasPrefix(strings.TrimSpace(line), "plan_path:") {
+				lines[i] = "plan_path: ./examples/terraform-plan.json"
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			lines = append(lines, "plan_path: ./examples/terraform-plan.json")
+		}
+		os.WriteFile(fullConfigPath, []byte(strings.Join(lines, "\n")), 0644)
+	}
+
+	job.Status = "completed"
+	job.Phase = "Plan generated successfully"
+	job.PlanPath = planJsonPath
+	job.DoneAt = time.Now()
+
+	// Clean up binary plan file
+	os.Remove(planBinaryPath)
+}
+
+// GET /api/terraform/job?id=<job_id> — Poll job status.
+func handleTerraformJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := r.URL.Query().Get("id")
+	if jobID == "" {
+		jsonError(w, "job id is required", http.StatusBadRequest)
+		return
+	}
+
+	tfJobsMu.Lock()
+	job, exists := tfJobs[jobID]
+	tfJobsMu.Unlock()
+
+	if !exists {
+		jsonError(w, "Job not found: "+jobID, http.StatusNotFound)
+		return
+	}
+
+	elapsed := time.Since(job.StartedAt).Seconds()
+	if !job.DoneAt.IsZero() {
+		elapsed = job.DoneAt.Sub(job.StartedAt).Seconds()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         job.ID,
+		"status":     job.Status,
+		"phase":      job.Phase,
+		"error":      job.Error,
+		"plan_path":  job.PlanPath,
+		"elapsed_s":  int(elapsed),
+		"output":     job.Output,
+		"redactions": job.Redactions,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Policy violation auto-fix
+// ---------------------------------------------------------------------------
+
+// defaultSSHCIDR replaces 0.0.0.0/0 in SSH ingress rules unless the request
+// names another range.
+const defaultSSHCIDR = "10.0.0.0/8"
+
+// AutoFix is the outcome of fixing one violation. Status is "fixed",
+// "already_fixed", "not_found" (no matching resource block or rule),
+// "not_applicable" (the block is not one the policy covers) or
+// "unsupported" (no fixer for the policy or address).
+type AutoFix struct {
+	PolicyID        string `json:"policy_id"`
+	ResourceAddress string `json:"resource_address"`
+	File            string `json:"file,omitempty"`
+	Status          string `json:"status"`
+	Message         string `json:"message,omitempty"`
+}
+
+// fixOptions are request settings passed to every fixer, with the current
+// content of every .tf file for fixers that look beyond their block.
+type fixOptions struct {
+	SSHCIDR string
+	sources map[string]string
+}
+
+// violationFixer rewrites a resource block so it no longer violates a
+// policy. fix returns the new file content, or errAlreadyFixed.
+type violationFixer struct {
+	resourceTypes []string
+	fix           func(src string, blk tfBlock, opts fixOptions) (string, error)
+}
+
+var errAlreadyFixed = fmt.Errorf("already fixed")
+
+// notApplicableError is returned by a fixer for a block the policy does not
+// cover, such as an egress rule for SG-001.
+type notApplicableError string
+
+func (e notApplicableError) Error() string { return string(e) }
+
+// violationFixers maps policy IDs to their fixer.
+var violationFixers = map[string]violationFixer{
+	"S3-001":  {[]string{"aws_s3_bucket"}, fixS3Encryption},
+	"EC2-001": {[]string{"aws_instance", "aws_launch_template"}, fixIMDSv2},
+	"SG-001": {[]string{"aws_security_group", "aws_security_group_rule",
+		"aws_vpc_security_group_ingress_rule"}, fixOpenSSH},
+}
+
+// fixS3Encryption adds default AES256 server-side encryption to a bucket as
+// an aws_s3_bucket_server_side_encryption_configuration resource after it,
+// since AWS provider v4 deprecates the inline block. A bucket that has the
+// inline block, or a configuration resource naming it, is already fixed.
+func fixS3Encryption(src string, blk tfBlock, opts fixOptions) (string, error) {
+	const encType = "aws_s3_bucket_server_side_encryption_configuration"
+	if len(blk.children(src, "server_side_encryption_configuration")) > 0 {
+		return "", errAlreadyFixed
+	}
+	ref := regexp.MustCompile(`\baws_s3_bucket\.` + regexp.QuoteMeta(blk.Name) + `\.(id|bucket)\b`)
+	_, _, bucketName, named := blk.attribute(src, "bucket")
+	taken := map[string]bool{}
+	for _, enc := range resourceBlocks(opts.sources, encType, "") {
+		taken[enc.Name] = true
+		_, _, v, ok := enc.attribute(opts.sources[enc.File], "bucket")
+		if ok && (ref.MatchString(v) || named && v == bucketName) {
+			return "", errAlreadyFixed
+		}
+	}
+	name := blk.Name
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s_%d", blk.Name, i)
+	}
+	return blk.insertAfter(src, []string{
+		fmt.Sprintf(`resource "%s" "%s" {`, encType, name),
+		fmt.Sprintf("  bucket = aws_s3_bucket.%s.id", blk.Name),
+		"",
+		"  rule {",
+		"    apply_server_side_encryption_by_default {",
+		`      sse_algorithm = "AES256"`,
+		"    }",
+		"  }",
+		"}",
+	}), nil
+}
+
+// fixIMDSv2 requires session tokens for the instance metadata service.
+func fixIMDSv2(src string, blk tfBlock, _ fixOptions) (string, error) {
+	opts := blk.children(src, "metadata_options")
+	if len(opts) == 0 {
+		return blk.insert(src, []string{
+			"metadata_options {",
+			`  http_tokens = "required"`,
+			"}",
+		}), nil
+	}
+	start, end, value, ok := opts[0].attribute(src, "http_tokens")
+	if !ok {
+		return opts[0].insert(src, []string{`http_tokens = "required"`}), nil
+	}
+	if strings.TrimSpace(value) == `"required"` {
+		return "", errAlreadyFixed
+	}
+	return src[:start] + `"required"` + src[end:], nil
+}
+
+// fixOpenSSH narrows 0.0.0.0/0 to opts.SSHCIDR in ingress rules that cover
+// port 22, whether written as ingress blocks or as separate rule resources.
+func fixOpenSSH(src string, blk tfBlock, opts fixOptions) (string, error) {
+	rules := []tfBlock{blk}
+	switch blk.Type {
+	case "aws_security_group":
+		rules = blk.children(src, "ingress")
+	case "aws_security_group_rule":
+		if _, _, v, _ := blk.attribute(src, "type"); strings.TrimSpace(v) != `"ingress"` {
+			return "", notApplicableError("not an ingress rule")
+		}
+	}
+
+	changed := false
+	// Rewrite from the last rule so earlier offsets stay valid.
+	for i := len(rules) - 1; i >= 0; i-- {
+		rule := rules[i]
+		if !rule.coversPort(src, 22) {
+			continue
+		}
+		for _, attr := range []string{"cidr_blocks", "cidr_ipv4"} {
+			start, end, value, ok := rule.attribute(src, attr)
+			if !ok || !strings.Contains(value, `"0.0.0.0/0"`) {
+				continue
+			}
+			src = src[:start] + strings.ReplaceAll(value, `"0.0.0.0/0"`, strconv.Quote(opts.SSHCIDR)) + src[end:]
+			changed = true
+		}
+	}
+	if !changed {
+		return "", errAlreadyFixed
+	}
+	return src, nil
+}
+
+// tfBlock is a block in a .tf file. Open and End are the offsets of its
+// braces; Type and Name are the labels of a resource block.
+type tfBlock struct {
+	File       string
+	Type, Name string
+	Open, End  int
+}
+
+// children returns the direct nested blocks of b with the given name.
+func (b tfBlock) children(src, name string) []tfBlock {
+	masked := maskHCL(src)
+	re := regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(name) + `[ \t]*\{`)
+	var blocks []tfBlock
+	for _, m := range re.FindAllStringIndex(masked[b.Open+1:b.End], -1) {
+		open := b.Open + 1 + m[1] - 1
+		if hclDepth(masked, b.Open+1, open) != 0 {
+			continue
+		}
+		if end := matchBrace(masked, open); end > 0 {
+			blocks = append(blocks, tfBlock{File: b.File, Type: name, Open: open, End: end})
+		}
+	}
+	return blocks
+}
+
+// attribute finds a direct attribute of b. It returns the offsets of the
+// value expression, which ends at the end of its line.
+func (b tfBlock) attribute(src, name string) (start, end int, value string, ok bool) {
+	masked := maskHCL(src)
+	re := regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(name) + `[ \t]*=[ \t]*`)
+	for _, m := range re.FindAllStringIndex(masked[b.Open+1:b.End], -1) {
+		start = b.Open + 1 + m[1]
+		if hclDepth(masked, b.Open+1, start) != 0 {
+			continue
+		}
+		end = start + strings.IndexByte(src[start:]+"\n", '\n')
+		value = strings.TrimRight(stripHCLComment(src[start:end]), " \t\r")
+		return start, start + len(value), value, true
+	}
+	return 0, 0, "", false
+}
+
+// coversPort reports whether a rule's from_port..to_port range includes
+// port, treating protocol "-1" or "all" as every port.
+func (b tfBlock) coversPort(src string, port int) bool {
+	if _, _, v, ok := b.attribute(src, "ip_protocol"); ok && (v == `"-1"` || v == `"all"`) {
+		return true
+	}
+	if _, _, v, ok := b.attribute(src, "protocol"); ok && (v == `"-1"` || v == `"all"` || v == "-1") {
+		return true
+	}
+	_, _, from, okFrom := b.attribute(src, "from_port")
+	_, _, to, okTo := b.attribute(src, "to_port")
+	if !okFrom || !okTo {
+		return false
+	}
+	lo, errFrom := strconv.Atoi(strings.Trim(from, `"`))
+	hi, errTo := strconv.Atoi(strings.Trim(to, `"`))
+	return errFrom == nil && errTo == nil && lo <= port && port <= hi
+}
+
+// insert adds lines at the end of b's body, indented like its other
+// contents.
+func (b tfBlock) insert(src string, lines []string) string {
+	lineStart := strings.LastIndexByte(src[:b.Open], '\n') + 1
+	outer := src[lineStart : lineStart+len(src[lineStart:b.Open])-len(strings.TrimLeft(src[lineStart:b.Open], " \t"))]
+	indent := outer + "  "
+	for _, line := range strings.Split(src[b.Open+1:b.End], "\n")[1:] {
+		if strings.TrimSpace(line) != "" {
+			indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			break
+		}
+	}
+	var text strings.Builder
+	for _, line := range lines {
+		text.WriteString(indent + line + "\n")
+	}
+
+	endLine := strings.LastIndexByte(src[:b.End], '\n') + 1
+	if endLine > b.Open && strings.TrimSpace(src[endLine:b.End]) == "" {
+		return src[:endLine] + text.String() + src[endLine:]
+	}
+	return src[:b.End] + "\n" + text.String() + outer + src[b.End:]
+}
+
+// insertAfter adds lines after b, separated by a blank line and indented
+// like b itself.
+func (b tfBlock) insertAfter(src string, lines []string) string {
+	lineStart := strings.LastIndexByte(src[:b.Open], '\n') + 1
+	indent := src[lineStart : lineStart+len(src[lineStart:b.Open])-len(strings.TrimLeft(src[lineStart:b.Open], " \t"))]
+	var text strings.Builder
+	for _, line := range lines {
+		text.WriteString("\n")
+		if line != "" {
+			text.WriteString(indent + line)
+		}
+	}
+	return src[:b.End+1] + "\n" + text.String() + src[b.End+1:]
+}
+
+// maskHCL blanks out strings, comments and heredocs so braces and keywords
+// can be matched on the result. Offsets and newlines are preserved.
+func maskHCL(src string) string {
+	b := []byte(src)
+	blank := func(i int) {
+		if b[i] != '\n' {
+			b[i] = ' '
+		}
+	}
+	for i := 0; i < len(b); i++ {
+		switch {
+		case src[i] == '"':
+			for i++; i < len(b) && src[i] != '"' && src[i] != '\n'; i++ {
+				if src[i] == '\\' && i+1 < len(b) {
+					blank(i)
+					i++
+				}
+				blank(i)
+			}
+		case src[i] == '#' || strings.HasPrefix(src[i:], "//"):
+			for ; i < len(b) && src[i] != '\n'; i++ {
+				blank(i)
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			stop := len(b)
+			if j := strings.Index(src[i+2:], "*/"); j >= 0 {
+				stop = i + 2 + j + 2
+			}
+			for ; i < stop; i++ {
+				blank(i)
+			}
+			i--
+		case strings.HasPrefix(src[i:], "<<"):
+			m := heredocStart.FindStringSubmatch(src[i:])
+			if m == nil {
+				continue
+			}
+			i += len(m[0])
+			for i < len(b) {
+				lineEnd := i + strings.IndexByte(src[i:]+"\n", '\n')
+				if strings.TrimSpace(src[i:min(lineEnd, len(src))]) == m[1] {
+					i = lineEnd - 1
+					break
+				}
+				for ; i < lineEnd && i < len(b); i++ {
+					blank(i)
+				}
+				i++
+			}
+		}
+	}
+	return string(b)
+}
+
+// stripHCLComment removes a trailing # or // comment from a line.
+func stripHCLComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case inString && line[i] == '\\':
+			i++
+		case line[i] == '"':
+			inString = !inString
+		case !inString && (line[i] == '#' || strings.HasPrefix(line[i:], "//")):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+var heredocStart = regexp.MustCompile(`^<<-?([A-Za-z_][A-Za-z0-9_]*)[ \t]*\n`)
+
+// matchBrace returns the offset of the brace closing the one at open in
+// masked source, or -1.
+func matchBrace(masked string, open int) int {
+	depth := 0
+	for i := open; i < len(masked); i++ {
+		switch masked[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// hclDepth is the brace depth at offset to, counted from offset from.
+func hclDepth(masked string, from, to int) int {
+	return strings.Count(masked[from:to], "{") - strings.Count(masked[from:to], "}")
+}
+
+// tfSourceFiles lists the .tf files of the terraform project directory.
+func tfSourceFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".tf") {
+			files = append(files, e.Name())
+		}
+	}
+	return files, nil
+}
+
+// findResourceBlock locates the block of a resource address, such as
+// aws_s3_bucket.logs or aws_instance.web[0], in the given sources.
+func findResourceBlock(sources map[string]string, address string) (tfBlock, error) {
+	if strings.HasPrefix(address, "module.") || strings.HasPrefix(address, "data.") {
+		return tfBlock{}, fmt.Errorf("only root module managed resources can be fixed")
+	}
+	if i := strings.IndexByte(address, '['); i >= 0 {
+		address = address[:i]
+	}
+	resType, name, ok := strings.Cut(address, ".")
+	if !ok {
+		return tfBlock{}, fmt.Errorf("invalid resource address")
+	}
+	if blocks := resourceBlocks(sources, resType, name); len(blocks) > 0 {
+		return blocks[0], nil
+	}
+	return tfBlock{}, fmt.Errorf("resource block not found")
+}
+
+// resourceBlocks returns the top-level resource blocks of resType, in file
+// order. An empty name matches every name.
+func resourceBlocks(sources map[string]string, resType, name string) []tfBlock {
+	namePattern := regexp.QuoteMeta(name)
+	if name == "" {
+		namePattern = `[^"]+`
+	}
+	re := regexp.MustCompile(`(?m)^[ \t]*resource[ \t]+"` + regexp.QuoteMeta(resType) + `"[ \t]+"(` + namePattern + `)"[ \t]*\{`)
+	var blocks []tfBlock
+	for _, file := range slices.Sorted(maps.Keys(sources)) {
+		src := sources[file]
+		masked := maskHCL(src)
+		for _, m := range re.FindAllStringSubmatchIndex(src, -1) {
+			open := m[1] - 1
+			if masked[open] != '{' || hclDepth(masked, 0, open) != 0 {
+				continue
+			}
+			if end := matchBrace(masked, open); end > 0 {
+				blocks = append(blocks, tfBlock{File: file, Type: resType, Name: src[m[2]:m[3]], Open: open, End: end})
+			}
+		}
+	}
+	return blocks
+}
+
+// unifiedDiff renders the change from before to after as a unified diff
+// with three lines of context.
+func unifiedDiff(name, before, after string) string {
+	a, b := strings.SplitAfter(before, "\n"), strings.SplitAfter(after, "\n")
+	if a[len(a)-1] == "" {
+		a = a[:len(a)-1]
+	}
+	if b[len(b)-1] == "" {
+		b = b[:len(b)-1]
+	}
+
+	// Trim the common prefix and suffix, then diff the middle by LCS.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	type op struct {
+		kind byte
+		line string
+	}
+	ops := []op{}
+	for _, line := range a[:pre] {
+		ops = append(ops, op{' ', line})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, op{' ', ma[i]})
+			i, j = i+1, j+1
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', ma[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', mb[j]})
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suf:] {
+		ops = append(ops, op{' ', line})
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+	aLine, bLine := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			aLine, bLine, k = aLine+1, bLine+1, k+1
+			continue
+		}
+		// Extend the hunk while changes are within 2*context lines.
+		start := max(0, k-context)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				end = min(len(ops), end+context)
+				break
+			}
+			end = next
+		}
+		aStart, bStart := aLine-(k-start), bLine-(k-start)
+		aCount, bCount := 0, 0
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", hunkStart(aStart, aCount), aCount, hunkStart(bStart, bCount), bCount)
+		for _, o := range ops[start:end] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		for _, o := range ops[k:end] {
+			if o.kind != '+' {
+				aLine++
+			}
+			if o.kind != '-' {
+				bLine++
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkStart is the 1-based start line of a hunk; empty ranges use the line
+// before them, as in diff(1).
+func hunkStart(offset, count int) int {
+	if count == 0 {
+		return offset
+	}
+	return offset + 1
+}
+
+// validateTerraform runs terraform validate in dir, initializing it without
+// a backend first if needed.
+func validateTerraform(dir string) (bool, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	var output strings.Builder
+	if _, err := os.Stat(filepath.Join(dir, ".terraform")); err != nil {
+		initCmd := exec.CommandContext(ctx, terraformPath(), "init", "-backend=false", "-no-color", "-input=false")
+		initCmd.Dir = dir
+		out, err := initCmd.CombinedOutput()
+		output.Write(out)
+		if err != nil {
+			return false, output.String()
+		}
+	}
+	validateCmd := exec.CommandContext(ctx, terraformPath(), "validate", "-no-color")
+	validateCmd.Dir = dir
+	out, err := validateCmd.CombinedOutput()
+	output.Write(out)
+	return err == nil, output.String()
+}
+
+// POST /api/terraform/fix — Generate patches for the violations of a scan
+// (the latest by default) against the uploaded .tf files, and optionally
+// apply them and re-run terraform validate.
+func handleTerraformFix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ScanID          string `json:"scan_id"`
+		PolicyID        string `json:"policy_id"`
+		ResourceAddress string `json:"resource_address"`
+		SSHCIDR         string `json:"ssh_cidr"`
+		Apply           bool   `json:"apply"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	opts := fixOptions{SSHCIDR: req.SSHCIDR}
+	if opts.SSHCIDR == "" {
+		opts.SSHCIDR = defaultSSHCIDR
+	}
+	if _, _, err := net.ParseCIDR(opts.SSHCIDR); err != nil {
+		jsonError(w, "ssh_cidr is not a valid CIDR: "+opts.SSHCIDR, http.StatusBadRequest)
+		return
+	}
+	if req.PolicyID != "" {
+		if _, ok := violationFixers[req.PolicyID]; !ok {
+			jsonError(w, "No auto-fix for policy: "+req.PolicyID, http.StatusBadRequest)
+			return
+		}
+	}
+
+	rec, err := latestOrNamedScan(req.ScanID)
+	if err != nil {
+		jsonError(w, "Scan not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	scan, err := rec.Scan()
+	if err != nil {
+		jsonError(w, "Failed to read scan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Apply {
+		if _, err := terraformVersion(); err != nil {
+			jsonError(w, "Terraform is not available to validate the fix", http.StatusServiceUnavailable)
+			return
+		}
+		if !tfMutex.TryLock() {
+			jsonError(w, "Another Terraform operation is already running", http.StatusConflict)
+			return
+		}
+		defer tfMutex.Unlock()
+	}
+
+	tfDir := filepath.Join(workDir(), "terraform")
+	names, err := tfSourceFiles(tfDir)
+	if err != nil || len(names) == 0 {
+		jsonError(w, "No .tf files found. Upload Terraform files first.", http.StatusBadRequest)
+		return
+	}
+	original := map[string]string{}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(tfDir, name))
+		if err != nil {
+			jsonError(w, "Failed to read "+name+": "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		original[name] = string(data)
+	}
+	sources := maps.Clone(original)
+	opts.sources = sources
+
+	fixes := []AutoFix{}
+	seen := map[string]bool{}
+	var findings []PolicyViolation
+	if scan.PolicyResult != nil {
+		findings = append(slices.Clone(scan.PolicyResult.Violations), scan.PolicyResult.Warnings...)
+	}
+	for _, v := range findings {
+		if (req.PolicyID != "" && v.PolicyID != req.PolicyID) ||
+			(req.ResourceAddress != "" && v.ResourceAddress != req.ResourceAddress) {
+			continue
+		}
+		fixer, ok := violationFixers[v.PolicyID]
+		key := v.PolicyID + "|" + v.ResourceAddress
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		fix := AutoFix{PolicyID: v.PolicyID, ResourceAddress: v.ResourceAddress}
+		blk, err := findResourceBlock(sources, v.ResourceAddress)
+		switch {
+		case err != nil:
+			fix.Status, fix.Message = "not_found", err.Error()
+		case !slices.Contains(fixer.resourceTypes, blk.Type):
+			fix.File, fix.Status = blk.File, "unsupported"
+			fix.Message = "no auto-fix for " + blk.Type
+		default:
+			fix.File = blk.File
+			updated, err := fixer.fix(sources[blk.File], blk, opts)
+			var notApplicable notApplicableError
+			switch {
+			case err == errAlreadyFixed:
+				fix.Status = "already_fixed"
+			case errors.As(err, &notApplicable):
+				fix.Status, fix.Message = "not_applicable", err.Error()
+			case err != nil:
+				fix.Status, fix.Message = "not_found", err.Error()
+			default:
+				sources[blk.File] = updated
+				fix.Status = "fixed"
+			}
+		}
+		fixes = append(fixes, fix)
+	}
+
+	files := []map[string]string{}
+	var patch strings.Builder
+	for _, name := range names {
+		if sources[name] == original[name] {
+			continue
+		}
+		diff := unifiedDiff(name, original[name], sources[name])
+		files = append(files, map[string]string{"file": name, "diff": diff})
+		patch.WriteString(diff)
+	}
+
+	resp := map[string]interface{}{
+		"scan_id": rec.ID,
+		"fixes":   fixes,
+		"files":   files,
+		"patch":   patch.String(),
+		"applied": false,
+	}
+	if req.Apply && len(files) > 0 {
+		for _, f := range files {
+			if err := os.WriteFile(filepath.Join(tfDir, f["file"]), []byte(sources[f["file"]]), 0644); err != nil {
+				jsonError(w, "Failed to write "+f["file"]+": "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		valid, output := validateTerraform(tfDir)
+		if !valid {
+			// Leave the project as it was when the patched code does not validate.
+			for _, f := range files {
+				os.WriteFile(filepath.Join(tfDir, f["file"]), []byte(original[f["file"]]), 0644)
+			}
+		}
+		resp["applied"] = valid
+		resp["validation"] = map[string]interface{}{"valid": valid, "output": output}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ---------------------------------------------------------------------------
+// Scan export endpoints
+// ---------------------------------------------------------------------------
+
+// GET /api/scans/{id}/export?format=sarif|junit|csv — Export a stored scan.
+func handleScanExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rec, err := loadScan(r.PathValue("id"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	scan, err := rec.Scan()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeExport(w, r.URL.Query().Get("format"), rec.ID, scan)
+}
+
+// POST /api/export?format=sarif|junit|csv — Export a scan result supplied in the body.
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var scan ScanResult
+	if err := json.NewDecoder(io.LimitReader(r.Body, 10*1024*1024)).Decode(&scan); err != nil {
+		jsonError(w, "Invalid scan result: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeExport(w, r.URL.Query().Get("format"), "scan", &scan)
+}
+
+func writeExport(w http.ResponseWriter, format, name string, scan *ScanResult) {
+	switch strings.ToLower(format) {
+	case "", "sarif":
+		w.Header().Set("Content-Type", "application/sarif+json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.sarif"`, name))
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(buildSARIF(scan))
+	case "junit":
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xml"`, name))
+		io.WriteString(w, xml.Header)
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		enc.Encode(buildJUnit(scan))
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		writeScanCSV(w, scan)
+	default:
+		jsonError(w, "Unsupported export format: "+format, http.StatusBadRequest)
+	}
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name,omitempty"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	Help                 *sarifMessage          `json:"help,omitempty"`
+	DefaultConfiguration map[string]string      `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	RuleIndex  int                    `json:"ruleIndex"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifDriftRuleID is the rule used for drift results, which have no policy ID.
+const sarifDriftRuleID = "DRIFT"
+
+// sarifLevel maps a Cloudrift severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity maps a Cloudrift severity to the numeric score code
+// scanning dashboards use to rank results.
+func securitySeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "9.5"
+	case "high":
+		return "8.0"
+	case "medium":
+		return "5.5"
+	case "low":
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// buildSARIF converts a scan result into a SARIF 2.1.0 log. Each policy ID
+// becomes a rule, violations and warnings become results located at the
+// Terraform resource address, and drift is reported as note-level results.
+func buildSARIF(scan *ScanResult) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "Cloudrift",
+			InformationURI: "https://github.com/inayathulla/cloudrift",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	ruleIndex := map[string]int{}
+
+	addRule := func(rule sarifRule) int {
+		if idx, ok := ruleIndex[rule.ID]; ok {
+			return idx
+		}
+		ruleIndex[rule.ID] = len(run.Tool.Driver.Rules)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		return ruleIndex[rule.ID]
+	}
+
+	location := func(address, name string) []sarifLocation {
+		return []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+			Name: name, FullyQualifiedName: address, Kind: "resource",
+		}}}}
+	}
+
+	addFinding := func(v PolicyViolation, warning bool) {
+		tags := []string{}
+		if v.Category != "" {
+			tags = append(tags, v.Category)
+		}
+		tags = append(tags, v.Frameworks...)
+		rule := sarifRule{
+			ID:                   v.PolicyID,
+			Name:                 v.PolicyName,
+			ShortDescription:     sarifMessage{Text: v.PolicyName},
+			DefaultConfiguration: map[string]string{"level": sarifLevel(v.Severity)},
+			Properties: map[string]interface{}{
+				"category":          v.Category,
+				"tags":              tags,
+				"security-severity": securitySeverity(v.Severity),
+			},
+		}
+		if rule.ShortDescription.Text == "" {
+			rule.ShortDescription.Text = v.PolicyID
+		}
+		if v.Remediation != "" {
+			rule.Help = &sarifMessage{
+				Text:     v.Remediation,
+				Markdown: fmt.Sprintf("**Remediation:** %s", v.Remediation),
+			}
+		}
+		level := sarifLevel(v.Severity)
+		if warning && level == "error" {
+			level = "warning"
+		}
+		name := resourceName(v.ResourceType, v.ResourceAddress)
+		if name == v.ResourceAddress {
+			if i := strings.Index(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    v.PolicyID,
+			RuleIndex: addRule(rule),
+			Level:     level,
+			Message:   sarifMessage{Text: v.Message},
+			Locations: location(v.ResourceAddress, name),
+			Properties: map[string]interface{}{
+				"severity":      strings.ToLower(v.Severity),
+				"resource_type": v.ResourceType,
+				"warning":       warning,
+			},
+		})
+	}
+
+	if scan.PolicyResult != nil {
+		for _, v := range scan.PolicyResult.Violations {
+			addFinding(v, false)
+		}
+		for _, v := range scan.PolicyResult.Warnings {
+			addFinding(v, true)
+		}
+	}
+
+	for _, d := range scan.Drifts {
+		idx := addRule(sarifRule{
+			ID:                   sarifDriftRuleID,
+			Name:                 "ConfigurationDrift",
+			ShortDescription:     sarifMessage{Text: "Live resource configuration differs from the Terraform plan"},
+			Help:                 &sarifMessage{Text: "Update the Terraform code to match the live resource, or re-apply the plan to restore the intended configuration."},
+			DefaultConfiguration: map[string]string{"level": "note"},
+		})
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    sarifDriftRuleID,
+			RuleIndex: idx,
+			Level:     "note",
+			Message:   sarifMessage{Text: driftMessage(d)},
+			Locations: location(d.Address(), d.Name()),
+			Properties: map[string]interface{}{
+				"severity":    strings.ToLower(d.Severity),
+				"resource_id": d.ResourceID,
+				"missing":     d.Missing,
+			},
+		})
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
+
+// driftMessage summarizes a drift entry in one line.
+func driftMessage(d DriftInfo) string {
+	if d.Missing {
+		return fmt.Sprintf("%s is in the Terraform plan but missing in AWS", d.Address())
+	}
+	attrs := make([]string, 0, len(d.Diffs))
+	for k := range d.Diffs {
+		attrs = append(attrs, k)
+	}
+	sort.Strings(attrs)
+	msg := fmt.Sprintf("%s has drifted", d.Address())
+	if len(attrs) > 0 {
+		msg += ": " + strings.Join(attrs, ", ")
+	}
+	if n := len(d.ExtraAttributes); n > 0 {
+		msg += fmt.Sprintf(" (%d unmanaged attribute(s))", n)
+	}
+	return msg
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// buildJUnit converts policy findings into JUnit test results: one suite
+// per policy ID and one test case per policy × resource. Violations fail,
+// warnings are skipped.
+func buildJUnit(scan *ScanResult) junitTestSuites {
+	root := junitTestSuites{Name: "cloudrift", Suites: []junitTestSuite{}}
+	if scan.PolicyResult == nil {
+		return root
+	}
+
+	suiteIndex := map[string]int{}
+	seen := map[string]bool{}
+	add := func(v PolicyViolation, warning bool) {
+		key := v.PolicyID + "|" + v.ResourceAddress
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		idx, ok := suiteIndex[v.PolicyID]
+		if !ok {
+			name := v.PolicyID
+			if v.PolicyName != "" {
+				name += " " + v.PolicyName
+			}
+			idx = len(root.Suites)
+			suiteIndex[v.PolicyID] = idx
+			root.Suites = append(root.Suites, junitTestSuite{Name: name})
+		}
+		suite := &root.Suites[idx]
+
+		tc := junitTestCase{ClassName: v.PolicyID, Name: v.ResourceAddress}
+		if warning {
+			tc.Skipped = &junitSkipped{Message: fmt.Sprintf("[%s] %s", v.Severity, v.Message)}
+			suite.Skipped++
+			root.Skipped++
+		} else {
+			text := v.Message
+			if v.Remediation != "" {
+				text += "\nRemediation: " + v.Remediation
+			}
+			tc.Failure = &junitFailure{Message: v.Message, Type: strings.ToLower(v.Severity), Text: text}
+			suite.Failures++
+			root.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		root.Tests++
+	}
+
+	for _, v := range scan.PolicyResult.Violations {
+		add(v, false)
+	}
+	for _, v := range scan.PolicyResult.Warnings {
+		add(v, true)
+	}
+	return root
+}
+
+// writeScanCSV flattens drifts and policy findings into a single CSV table.
+// The type column tells rows apart: drift, violation or warning.
+func writeScanCSV(w io.Writer, scan *ScanResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"type", "resource", "attribute", "expected", "actual",
+		"policy", "policy_name", "severity", "frameworks", "remediation", "message",
+	})
+
+	for _, d := range scan.Drifts {
+		resource := d.Address()
+		if d.Missing {
+			cw.Write(csvRow("drift", resource, "", "present", "missing", "", "", d.Severity, "", "", driftMessage(d)))
+		}
+		attrs := make([]string, 0, len(d.Diffs))
+		for k := range d.Diffs {
+			attrs = append(attrs, k)
+		}
+		sort.Strings(attrs)
+		for _, attr := range attrs {
+			expected, actual := diffPair(d.Diffs[attr])
+			cw.Write(csvRow("drift", resource, attr, csvValue(expected), csvValue(actual), "", "", d.Severity, "", "", ""))
+		}
+		extras := make([]string, 0, len(d.ExtraAttributes))
+		for k := range d.ExtraAttributes {
+			extras = append(extras, k)
+		}
+		sort.Strings(extras)
+		for _, attr := range extras {
+			cw.Write(csvRow("drift", resource, attr, "", csvValue(d.ExtraAttributes[attr]), "", "", d.Severity, "", "", "unmanaged attribute"))
+		}
+	}
+
+	if scan.PolicyResult != nil {
+		write := func(kind string, v PolicyViolation) {
+			cw.Write(csvRow(
+				kind, v.ResourceAddress, "", "", "",
+				v.PolicyID, v.PolicyName, v.Severity, strings.Join(v.Frameworks, ";"), v.Remediation, v.Message,
+			))
+		}
+		for _, v := range scan.PolicyResult.Violations {
+			write("violation", v)
+		}
+		for _, v := range scan.PolicyResult.Warnings {
+			write("warning", v)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvRow builds a CSV row whose cells cannot be evaluated as formulas by a
+// spreadsheet: a cell starting with =, +, -, @, tab or carriage return gets
+// a leading single quote.
+func csvRow(cells ...string) []string {
+	for i, c := range cells {
+		if c != "" && strings.ContainsRune("=+-@\t\r", rune(c[0])) {
+			cells[i] = "'" + c
+		}
+	}
+	return cells
+}
+
+// csvValue renders an attribute value for a CSV cell.
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Compliance report endpoint
+// ---------------------------------------------------------------------------
+
+// complianceFrameworks lists the CLI's built-in frameworks in report order.
+var complianceFrameworks = []struct{ Key, Label string }{
+	{"hipaa", "HIPAA"},
+	{"gdpr", "GDPR"},
+	{"iso_27001", "ISO 27001"},
+	{"pci_dss", "PCI DSS"},
+	{"soc2", "SOC 2"},
+}
+
+// severityOrder lists severities from most to least severe.
+var severityOrder = []string{"critical", "high", "medium", "low", "info"}
+
+func severityRank(severity string) int {
+	for i, s := range severityOrder {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return len(severityOrder)
+}
+
+type complianceReport struct {
+	GeneratedAt    time.Time
+	Scans          []reportScan
+	Services       []string
+	TotalResources int
+	DriftCount     int
+	Violations     int
+	Warnings       int
+	SeverityCounts []reportCount
+	Overall        ComplianceEntry
+	Frameworks     []reportFramework
+	Findings       []reportFinding
+	Drifts         []reportDrift
+}
+
+type reportScan struct {
+	ID             string
+	Service        string
+	AccountID      string
+	Region         string
+	Timestamp      string
+	TotalResources int
+	DriftCount     int
+}
+
+type reportCount struct {
+	Label string
+	Count int
+}
+
+type reportFramework struct {
+	Key     string
+	Label   string
+	Score   ComplianceEntry
+	Passing bool
+	Failing []reportPolicyRow
+}
+
+type reportPolicyRow struct {
+	PolicyID   string
+	PolicyName string
+	Severity   string
+	Resources  []string
+}
+
+type reportFinding struct {
+	Service string
+	Kind    string
+	PolicyViolation
+}
+
+type reportDrift struct {
+	Service  string
+	Address  string
+	Severity string
+	Missing  bool
+	Rows     []reportDriftRow
+}
+
+type reportDriftRow struct {
+	Attribute string
+	Expected  string
+	Actual    string
+}
+
+// complianceScore builds an entry from pass/fail counts.
+func complianceScore(passed, failed int) ComplianceEntry {
+	e := ComplianceEntry{Passed: passed, Failed: failed, Total: passed + failed, Percentage: 100}
+	if e.Total > 0 {
+		e.Percentage = math.Round(float64(passed)/float64(e.Total)*1000) / 10
+	}
+	return e
+}
+
+// buildComplianceReport aggregates one or more stored scans into a report.
+// Compliance counts are summed across scans and percentages recomputed.
+func buildComplianceReport(recs []*StoredScan) (*complianceReport, error) {
+	report := &complianceReport{GeneratedAt: time.Now().UTC()}
+	severities := map[string]int{}
+	services := map[string]bool{}
+	var passing, failing int
+	fwPassed := map[string]int{}
+	fwFailed := map[string]int{}
+	fwFailing := map[string]map[string]*reportPolicyRow{}
+
+	for _, rec := range recs {
+		scan, err := rec.Scan()
+		if err != nil {
+			return nil, err
+		}
+		report.Scans = append(report.Scans, reportScan{
+			ID: rec.ID, Service: scan.Service, AccountID: scan.AccountID, Region: scan.Region,
+			Timestamp: scan.Timestamp, TotalResources: scan.TotalResources, DriftCount: scan.DriftCount,
+		})
+		if !services[scan.Service] {
+			services[scan.Service] = true
+			report.Services = append(report.Services, scan.Service)
+		}
+		report.TotalResources += scan.TotalResources
+		report.DriftCount += scan.DriftCount
+
+		if pr := scan.PolicyResult; pr != nil {
+			if c := pr.Compliance; c != nil {
+				passing += c.PassingPolicies
+				failing += c.FailingPolicies
+				for key, e := range c.Frameworks {
+					fwPassed[key] += e.Passed
+					fwFailed[key] += e.Failed
+				}
+			} else {
+				passing += pr.Passed
+				failing += pr.Failed
+			}
+			for _, v := range pr.Violations {
+				report.Violations++
+				severities[strings.ToLower(v.Severity)]++
+				report.Findings = append(report.Findings, reportFinding{Service: scan.Service, Kind: "violation", PolicyViolation: v})
+				for _, fw := range v.Frameworks {
+					if fwFailing[fw] == nil {
+						fwFailing[fw] = map[string]*reportPolicyRow{}
+					}
+					row := fwFailing[fw][v.PolicyID]
+					if row == nil {
+						row = &reportPolicyRow{PolicyID: v.PolicyID, PolicyName: v.PolicyName, Severity: v.Severity}
+						fwFailing[fw][v.PolicyID] = row
+					}
+					if !slices.Contains(row.Resources, v.ResourceAddress) {
+						row.Resources = append(row.Resources, v.ResourceAddress)
+					}
+				}
+			}
+			for _, v := range pr.Warnings {
+				report.Warnings++
+				report.Findings = append(report.Findings, reportFinding{Service: scan.Service, Kind: "warning", PolicyViolation: v})
+			}
+		}
+
+		for _, d := range scan.Drifts {
+			rd := reportDrift{
+				Service: scan.Service, Address: d.Address(),
+				Severity: d.Severity, Missing: d.Missing,
+			}
+			attrs := make([]string, 0, len(d.Diffs))
+			for k := range d.Diffs {
+				attrs = append(attrs, k)
+			}
+			sort.Strings(attrs)
+			for _, attr := range attrs {
+				expected, actual := diffPair(d.Diffs[attr])
+				rd.Rows = append(rd.Rows, reportDriftRow{Attribute: attr, Expected: csvValue(expected), Actual: csvValue(actual)})
+			}
+			extras := make([]string, 0, len(d.ExtraAttributes))
+			for k := range d.ExtraAttributes {
+				extras = append(extras, k)
+			}
+			sort.Strings(extras)
+			for _, attr := range extras {
+				rd.Rows = append(rd.Rows, reportDriftRow{Attribute: attr, Expected: "(unmanaged)", Actual: csvValue(d.ExtraAttributes[attr])})
+			}
+			report.Drifts = append(report.Drifts, rd)
+		}
+	}
+
+	report.Overall = complianceScore(passing, failing)
+	for _, sev := range severityOrder {
+		report.SeverityCounts = append(report.SeverityCounts, reportCount{Label: sev, Count: severities[sev]})
+	}
+	for _, fw := range complianceFrameworks {
+		rf := reportFramework{Key: fw.Key, Label: fw.Label, Score: complianceScore(fwPassed[fw.Key], fwFailed[fw.Key])}
+		for _, row := range fwFailing[fw.Key] {
+			rf.Failing = append(rf.Failing, *row)
+		}
+		sort.Slice(rf.Failing, func(i, j int) bool {
+			a, b := rf.Failing[i], rf.Failing[j]
+			if severityRank(a.Severity) != severityRank(b.Severity) {
+				return severityRank(a.Severity) < severityRank(b.Severity)
+			}
+			return a.PolicyID < b.PolicyID
+		})
+		rf.Passing = rf.Score.Failed == 0 && len(rf.Failing) == 0
+		report.Frameworks = append(report.Frameworks, rf)
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Kind != b.Kind {
+			return a.Kind == "violation"
+		}
+		return severityRank(a.Severity) < severityRank(b.Severity)
+	})
+	return report, nil
+}
+
+// Summary is the executive summary paragraph shared by the HTML and PDF output.
+func (r *complianceReport) Summary() string {
+	passingFrameworks := 0
+	for _, fw := range r.Frameworks {
+		if fw.Passing {
+			passingFrameworks++
+		}
+	}
+	critical, high := 0, 0
+	for _, c := range r.SeverityCounts {
+		switch c.Label {
+		case "critical":
+			critical = c.Count
+		case "high":
+			high = c.Count
+		}
+	}
+	return fmt.Sprintf(
+		"This report covers %d scan(s) of %s with %d resources in total. "+
+			"Overall compliance is %.1f%% (%d of %d policy checks passing). "+
+			"The scans found %d policy violation(s), including %d critical and %d high, and %d warning(s). "+
+			"%d resource(s) have drifted from their Terraform definition. "+
+			"%d of %d compliance frameworks have no failing controls.",
+		len(r.Scans), strings.Join(r.Services, ", "), r.TotalResources,
+		r.Overall.Percentage, r.Overall.Passed, r.Overall.Total,
+		r.Violations, critical, high, r.Warnings,
+		r.DriftCount,
+		passingFrameworks, len(r.Frameworks))
+}
+
+// GET /api/reports/compliance?scan=<id>&format=html|pdf — Render a
+// standalone compliance report. Repeat scan= to combine several scans; with
+// none, the latest scan of each service is used.
+func handleComplianceReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var recs []*StoredScan
+	if ids := r.URL.Query()["scan"]; len(ids) > 0 {
+		for _, id := range ids {
+			rec, err := loadScan(id)
+			if err != nil {
+				jsonError(w, "Scan not found: "+id, http.StatusNotFound)
+				return
+			}
+			recs = append(recs, rec)
+		}
+	} else {
+		all, err := listScans()
+		if err != nil {
+			jsonError(w, "Failed to list scans: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		seen := map[string]bool{}
+		for _, rec := range all {
+			if !seen[rec.Service] {
+				seen[rec.Service] = true
+				recs = append(recs, rec)
+			}
+		}
+	}
+	if len(recs) == 0 {
+		jsonError(w, "No scans available. Run a scan first.", http.StatusNotFound)
+		return
+	}
+
+	report, err := buildComplianceReport(recs)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := "compliance-report-" + report.GeneratedAt.Format("20060102-150405")
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "html":
+		var buf bytes.Buffer
+		if err := reportTemplate.Execute(&buf, report); err != nil {
+			jsonError(w, "Failed to render report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.html"`, filename))
+		w.Write(buf.Bytes())
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
+		w.Write(renderReportPDF(report))
+	default:
+		jsonError(w, "Unsupported report format: "+format, http.StatusBadRequest)
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct":   func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Cloudrift Compliance Report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2933; margin: 40px; font-size: 14px; }
+h1 { margin-bottom: 4px; }
+h2 { border-bottom: 2px solid #3f51b5; padding-bottom: 4px; margin-top: 36px; }
+h3 { margin-top: 24px; }
+table { border-collapse: collapse; width: 100%; margin: 12px 0; }
+th, td { border: 1px solid #d9dee5; padding: 6px 8px; text-align: left; vertical-align: top; }
+th { background: #eef0f6; }
+.muted { color: #6b7785; }
+.pass { color: #1b873f; font-weight: 600; }
+.fail { color: #c62828; font-weight: 600; }
+.sev { text-transform: uppercase; font-size: 11px; font-weight: 700; }
+.sev-critical { color: #b71c1c; } .sev-high { color: #e65100; } .sev-medium { color: #f9a825; } .sev-low { color: #2e7d32; } .sev-info { color: #546e7a; }
+code { font-family: "SFMono-Regular", Consolas, monospace; font-size: 12px; }
+@media print { body { margin: 0; } h2 { page-break-after: avoid; } }
+</style>
+</head>
+<body>
+<h1>Cloudrift Compliance Report</h1>
+<p class="muted">Generated {{.GeneratedAt.Format "2006-01-02 15:04 UTC"}}</p>
+
+<h2>Executive Summary</h2>
+<p>{{.Summary}}</p>
+<table>
+<tr><th>Overall compliance</th><td>{{pct .Overall.Percentage}} ({{.Overall.Passed}}/{{.Overall.Total}})</td></tr>
+<tr><th>Resources scanned</th><td>{{.TotalResources}}</td></tr>
+<tr><th>Drifted resources</th><td>{{.DriftCount}}</td></tr>
+<tr><th>Violations</th><td>{{.Violations}}{{range .SeverityCounts}}{{if .Count}} &middot; <span class="sev sev-{{.Label}}">{{.Label}}</span> {{.Count}}{{end}}{{end}}</td></tr>
+<tr><th>Warnings</th><td>{{.Warnings}}</td></tr>
+</table>
+<table>
+<tr><th>Scan</th><th>Service</th><th>Account</th><th>Region</th><th>Timestamp</th><th>Resources</th><th>Drift</th></tr>
+{{range .Scans}}<tr><td><code>{{.ID}}</code></td><td>{{.Service}}</td><td>{{.AccountID}}</td><td>{{.Region}}</td><td>{{.Timestamp}}</td><td>{{.TotalResources}}</td><td>{{.DriftCount}}</td></tr>
+{{end}}</table>
+
+<h2>Framework Compliance</h2>
+<table>
+<tr><th>Framework</th><th>Passed</th><th>Failed</th><th>Total</th><th>Score</th><th>Status</th></tr>
+{{range .Frameworks}}<tr><td>{{.Label}}</td><td>{{.Score.Passed}}</td><td>{{.Score.Failed}}</td><td>{{.Score.Total}}</td><td>{{pct .Score.Percentage}}</td><td>{{if .Passing}}<span class="pass">PASS</span>{{else}}<span class="fail">FAIL</span>{{end}}</td></tr>
+{{end}}</table>
+{{range .Frameworks}}
+<h3>{{.Label}}</h3>
+{{if .Failing}}<table>
+<tr><th>Policy</th><th>Name</th><th>Severity</th><th>Affected resources</th><th>Status</th></tr>
+{{range .Failing}}<tr><td><code>{{.PolicyID}}</code></td><td>{{.PolicyName}}</td><td><span class="sev sev-{{lower .Severity}}">{{.Severity}}</span></td><td>{{range .Resources}}<code>{{.}}</code><br>{{end}}</td><td><span class="fail">FAIL</span></td></tr>
+{{end}}</table>
+{{else}}<p class="pass">No failing policies mapped to {{.Label}}.</p>{{end}}
+{{end}}
+
+<h2>Violation Details</h2>
+{{if .Findings}}<table>
+<tr><th>Type</th><th>Severity</th><th>Policy</th><th>Resource</th><th>Finding</th><th>Remediation</th><th>Frameworks</th></tr>
+{{range .Findings}}<tr><td>{{.Kind}}</td><td><span class="sev sev-{{lower .Severity}}">{{.Severity}}</span></td><td><code>{{.PolicyID}}</code><br>{{.PolicyName}}</td><td><code>{{.ResourceAddress}}</code></td><td>{{.Message}}</td><td>{{.Remediation}}</td><td>{{join .Frameworks ", "}}</td></tr>
+{{end}}</table>
+{{else}}<p>No policy violations or warnings.</p>{{end}}
+
+<h2>Appendix: Drift</h2>
+{{if .Drifts}}{{range .Drifts}}
+<h3><code>{{.Address}}</code> <span class="sev sev-{{lower .Severity}}">{{.Severity}}</span></h3>
+{{if .Missing}}<p class="fail">Resource is defined in Terraform but missing in AWS.</p>{{end}}
+{{if .Rows}}<table>
+<tr><th>Attribute</th><th>Expected (Terraform)</th><th>Actual (AWS)</th></tr>
+{{range .Rows}}<tr><td><code>{{.Attribute}}</code></td><td><code>{{.Expected}}</code></td><td><code>{{.Actual}}</code></td></tr>
+{{end}}</table>{{end}}
+{{end}}{{else}}<p>No drift detected.</p>{{end}}
+</body>
+</html>
+`))
+
+// renderReportPDF lays out the report as a PDF using the standard Helvetica
+// fonts, so no font files or network access are needed.
+func renderReportPDF(report *complianceReport) []byte {
+	pdf := newPDFWriter()
+	pdf.textLine("Cloudrift Compliance Report", 20, true)
+	pdf.paragraph("Generated "+report.GeneratedAt.Format("2006-01-02 15:04 UTC"), 9, false)
+
+	pdf.heading("Executive Summary")
+	pdf.paragraph(report.Summary(), 10, false)
+	pdf.space(6)
+	violations := fmt.Sprintf("%d", report.Violations)
+	for _, c := range report.SeverityCounts {
+		if c.Count > 0 {
+			violations += fmt.Sprintf(", %s %d", c.Label, c.Count)
+		}
+	}
+	pdf.table([]float64{160, 339}, []string{"Metric", "Value"}, [][]string{
+		{"Overall compliance", fmt.Sprintf("%.1f%% (%d/%d)", report.Overall.Percentage, report.Overall.Passed, report.Overall.Total)},
+		{"Resources scanned", fmt.Sprint(report.TotalResources)},
+		{"Drifted resources", fmt.Sprint(report.DriftCount)},
+		{"Violations", violations},
+		{"Warnings", fmt.Sprint(report.Warnings)},
+	})
+	scanRows := [][]string{}
+	for _, s := range report.Scans {
+		scanRows = append(scanRows, []string{s.ID, s.Service, s.AccountID, s.Region, s.Timestamp})
+	}
+	pdf.table([]float64{150, 50, 90, 70, 139}, []string{"Scan", "Service", "Account", "Region", "Timestamp"}, scanRows)
+
+	pdf.heading("Framework Compliance")
+	fwRows := [][]string{}
+	for _, fw := range report.Frameworks {
+		status := "FAIL"
+		if fw.Passing {
+			status = "PASS"
+		}
+		fwRows = append(fwRows, []string{
+			fw.Label, fmt.Sprint(fw.Score.Passed), fmt.Sprint(fw.Score.Failed), fmt.Sprint(fw.Score.Total),
+			fmt.Sprintf("%.1f%%", fw.Score.Percentage), status,
+		})
+	}
+	pdf.table([]float64{129, 70, 70, 70, 80, 80}, []string{"Framework", "Passed", "Failed", "Total", "Score", "Status"}, fwRows)
+	for _, fw := range report.Frameworks {
+		pdf.subheading(fw.Label)
+		if len(fw.Failing) == 0 {
+			pdf.paragraph("No failing policies mapped to "+fw.Label+".", 10, false)
+			continue
+		}
+		rows := [][]string{}
+		for _, p := range fw.Failing {
+			rows = append(rows, []string{p.PolicyID, p.PolicyName, p.Severity, strings.Join(p.Resources, "\n"), "FAIL"})
+		}
+		pdf.table([]float64{60, 140, 60, 189, 50}, []string{"Policy", "Name", "Severity", "Affected resources", "Status"}, rows)
+	}
+
+	pdf.heading("Violation Details")
+	if len(report.Findings) == 0 {
+		pdf.paragraph("No policy violations or warnings.", 10, false)
+	} else {
+		rows := [][]string{}
+		for _, f := range report.Findings {
+			rows = append(rows, []string{f.Severity + "\n" + f.Kind, f.PolicyID, f.ResourceAddress, f.Message, f.Remediation})
+		}
+		pdf.table([]float64{55, 50, 120, 137, 137}, []string{"Severity", "Policy", "Resource", "Finding", "Remediation"}, rows)
+	}
+
+	pdf.heading("Appendix: Drift")
+	if len(report.Drifts) == 0 {
+		pdf.paragraph("No drift detected.", 10, false)
+	}
+	for _, d := range report.Drifts {
+		pdf.subheading(fmt.Sprintf("%s (%s)", d.Address, d.Severity))
+		if d.Missing {
+			pdf.paragraph("Resource is defined in Terraform but missing in AWS.", 10, false)
+		}
+		if len(d.Rows) > 0 {
+			rows := [][]string{}
+			for _, row := range d.Rows {
+				rows = append(rows, []string{row.Attribute, row.Expected, row.Actual})
+			}
+			pdf.table([]float64{149, 175, 175}, []string{"Attribute", "Expected (Terraform)", "Actual (AWS)"}, rows)
+		}
+	}
+	return pdf.bytes()
+}
+
+// ---------------------------------------------------------------------------
+// Compliance posture trend
+// ---------------------------------------------------------------------------
+
+// postureFile is an append-only JSON Lines log with one record per scan.
+const postureFile = "posture.jsonl"
+
+// PostureRecord is the compliance posture captured after a scan.
+type PostureRecord struct {
+	ScanID             string             `json:"scan_id"`
+	RecordedAt         time.Time          `json:"recorded_at"`
+	Service            string             `json:"service"`
+	AccountID          string             `json:"account_id"`
+	Region             string             `json:"region"`
+	OverallPercentage  float64            `json:"overall_percentage"`
+	Frameworks         map[string]float64 `json:"frameworks"`
+	Categories         map[string]float64 `json:"categories"`
+	DriftCount         int                `json:"drift_count"`
+	Violations         int                `json:"violations"`
+	Warnings           int                `json:"warnings"`
+	CriticalViolations int                `json:"critical_violations"`
+}
+
+// PosturePoint aggregates the posture records of one time bucket.
+type PosturePoint struct {
+	Start              time.Time          `json:"start"`
+	Scans              int                `json:"scans"`
+	Scopes             int                `json:"scopes"`
+	OverallPercentage  float64            `json:"overall_percentage"`
+	Frameworks         map[string]float64 `json:"frameworks"`
+	Categories         map[string]float64 `json:"categories"`
+	DriftCount         int                `json:"drift_count"`
+	Violations         int                `json:"violations"`
+	Warnings           int                `json:"warnings"`
+	CriticalViolations int                `json:"critical_violations"`
+}
+
+var postureMu sync.Mutex
+
+// postureOf builds the posture record of a stored scan. Scans without a
+// compliance score, such as those run with skip_policies or whose result
+// does not parse, have no posture and return false.
+func postureOf(rec *StoredScan) (PostureRecord, bool) {
+	scan, err := rec.Scan()
+	if err != nil || scan.PolicyResult == nil || scan.PolicyResult.Compliance == nil {
+		return PostureRecord{}, false
+	}
+	summary := summarizeScan(rec)
+	c := scan.PolicyResult.Compliance
+	p := PostureRecord{
+		ScanID:             rec.ID,
+		RecordedAt:         rec.CreatedAt,
+		Service:            summary.Service,
+		AccountID:          summary.AccountID,
+		Region:             summary.Region,
+		OverallPercentage:  c.OverallPercentage,
+		Frameworks:         map[string]float64{},
+		Categories:         map[string]float64{},
+		DriftCount:         summary.DriftCount,
+		Violations:         summary.Violations,
+		Warnings:           summary.Warnings,
+		CriticalViolations: summary.CriticalViolations,
+	}
+	for k, e := range c.Frameworks {
+		p.Frameworks[k] = e.Percentage
+	}
+	for k, fs := range c.CustomFrameworks {
+		if fs.Total > 0 {
+			p.Frameworks[k] = fs.Percentage
+		}
+	}
+	for k, e := range c.Categories {
+		p.Categories[k] = e.Percentage
+	}
+	return p, true
+}
+
+// recordPosture appends the posture of a stored scan to the posture log.
+// Custom framework scores are recorded alongside the built-in frameworks.
+// Scans without a compliance score are not recorded, so they cannot replace
+// a scope's real score in the trend.
+func recordPosture(rec *StoredScan) {
+	p, ok := postureOf(rec)
+	if !ok {
+		return
+	}
+	line, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("Failed to encode posture record: %v", err)
+		return
+	}
+
+	postureMu.Lock()
+	defer postureMu.Unlock()
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		log.Printf("Failed to record posture: %v", err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dataDir(), postureFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Failed to record posture: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to record posture: %v", err)
+	}
+}
+
+// backfillPosture brings the posture log in line with the stored scans. It
+// adds scans that were never recorded and drops records of stored scans
+// that have no compliance score, which older versions logged as 100%.
+// Records of scans that have since been deleted are kept. The log is only
+// rewritten when something changed.
+func backfillPosture() error {
+	scans, err := listScans()
+	if err != nil {
+		return err
+	}
+	stored := map[string]*StoredScan{}
+	for _, rec := range scans {
+		stored[rec.ID] = rec
+	}
+
+	postureMu.Lock()
+	defer postureMu.Unlock()
+	path := filepath.Join(dataDir(), postureFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	records, recorded, changed := []PostureRecord{}, map[string]bool{}, false
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var p PostureRecord
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &p) != nil {
+			continue
+		}
+		if rec, ok := stored[p.ScanID]; ok {
+			if _, ok := postureOf(rec); !ok {
+				changed = true
+				continue
+			}
+		}
+		records = append(records, p)
+		recorded[p.ScanID] = true
+	}
+	for _, rec := range scans {
+		if recorded[rec.ID] {
+			continue
+		}
+		if p, ok := postureOf(rec); ok {
+			records = append(records, p)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].RecordedAt.Before(records[j].RecordedAt) })
+	var buf bytes.Buffer
+	for _, p := range records {
+		line, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadPosture reads the posture log oldest first, skipping lines that do
+// not parse.
+func loadPosture() ([]PostureRecord, error) {
+	postureMu.Lock()
+	data, err := os.ReadFile(filepath.Join(dataDir(), postureFile))
+	postureMu.Unlock()
+	if os.IsNotExist(err) {
+		return []PostureRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := []PostureRecord{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var p PostureRecord
+		if len(bytes.TrimSpace(line)) > 0 && json.Unmarshal(line, &p) == nil {
+			records = append(records, p)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].RecordedAt.Before(records[j].RecordedAt) })
+	return records, nil
+}
+
+// bucketStart truncates t to the start of its UTC day or week. Weeks start
+// on Monday.
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if bucket == "week" {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// aggregatePosture builds one point per bucket from records sorted oldest
+// first. Within a bucket only the latest record of each service and account
+// counts, so a point is the posture at the end of the bucket: percentages
+// are averaged over those scopes and counts are summed.
+func aggregatePosture(records []PostureRecord, bucket string) []PosturePoint {
+	type group struct {
+		start  time.Time
+		scans  int
+		latest map[string]PostureRecord
+	}
+	var groups []*group
+	for _, rec := range records {
+		start := bucketStart(rec.RecordedAt, bucket)
+		if len(groups) == 0 || !groups[len(groups)-1].start.Equal(start) {
+			groups = append(groups, &group{start: start, latest: map[string]PostureRecord{}})
+		}
+		g := groups[len(groups)-1]
+		g.scans++
+		g.latest[rec.Service+"/"+rec.AccountID] = rec
+	}
+
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	average := func(sums map[string]float64, counts map[string]int) map[string]float64 {
+		out := map[string]float64{}
+		for k, sum := range sums {
+			out[k] = round(sum / float64(counts[k]))
+		}
+		return out
+	}
+
+	points := []PosturePoint{}
+	for _, g := range groups {
+		pt := PosturePoint{Start: g.start, Scans: g.scans, Scopes: len(g.latest)}
+		var overall float64
+		fwSum, fwCount := map[string]float64{}, map[string]int{}
+		catSum, catCount := map[string]float64{}, map[string]int{}
+		for _, rec := range g.latest {
+			overall += rec.OverallPercentage
+			for k, v := range rec.Frameworks {
+				fwSum[k] += v
+				fwCount[k]++
+			}
+			for k, v := range rec.Categories {
+				catSum[k] += v
+				catCount[k]++
+			}
+			pt.DriftCount += rec.DriftCount
+			pt.Violations += rec.Violations
+			pt.Warnings += rec.Warnings
+			pt.CriticalViolations += rec.CriticalViolations
+		}
+		pt.OverallPercentage = round(overall / float64(len(g.latest)))
+		pt.Frameworks = average(fwSum, fwCount)
+		pt.Categories = average(catSum, catCount)
+		points = append(points, pt)
+	}
+	return points
+}
+
+// parseTrendTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date. A date
+// used as the end of a range includes that whole day.
+func parseTrendTime(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err == nil && end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, err
+}
+
+// GET /api/posture/trend?bucket=day|week&service=&account=&from=&to= —
+// Compliance posture over time, one point per day or week.
+func handlePostureTrend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	bucket := q.Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	if bucket != "day" && bucket != "week" {
+		jsonError(w, "bucket must be day or week", http.StatusBadRequest)
+		return
+	}
+	var from, to time.Time
+	if v := q.Get("from"); v != "" {
+		t, err := parseTrendTime(v, false)
+		if err != nil {
+			jsonError(w, "from must be an RFC 3339 time or YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := parseTrendTime(v, true)
+		if err != nil {
+			jsonError(w, "to must be an RFC 3339 time or YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+
+	records, err := loadPosture()
+	if err != nil {
+		jsonError(w, "Failed to load posture history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	service, account := strings.ToLower(q.Get("service")), q.Get("account")
+	filtered := []PostureRecord{}
+	for _, rec := range records {
+		if service != "" && rec.Service != service {
+			continue
+		}
+		if account != "" && rec.AccountID != account {
+			continue
+		}
+		if (!from.IsZero() && rec.RecordedAt.Before(from)) || (!to.IsZero() && !rec.RecordedAt.Before(to)) {
+			continue
+		}
+		filtered = append(filtered, rec)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bucket":  bucket,
+		"service": service,
+		"account": account,
+		"points":  aggregatePosture(filtered, bucket),
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Compliance evidence bundles
+// ---------------------------------------------------------------------------
+
+// EvidenceManifest describes the contents of an evidence bundle. It is
+// signed with the server's Ed25519 key; the signature is stored alongside
+// it in manifest.sig.
+type EvidenceManifest struct {
+	BundleID         string            `json:"bundle_id"`
+	CreatedAt        time.Time         `json:"created_at"`
+	ScanID           string            `json:"scan_id"`
+	ScannedAt        time.Time         `json:"scanned_at"`
+	Service          string            `json:"service"`
+	AccountID        string            `json:"account_id"`
+	Region           string            `json:"region"`
+	CLIVersion       string            `json:"cli_version"`
+	TerraformVersion string            `json:"terraform_version"`
+	ConfigPath       string            `json:"config_path"`
+	PlanPath         string            `json:"plan_path"`
+	PolicySet        EvidencePolicySet `json:"policy_set"`
+	Files            []EvidenceFile    `json:"files"`
+	Algorithm        string            `json:"algorithm"`
+	KeyID            string            `json:"key_id"`
+}
+
+// EvidencePolicySet identifies the policies a scan was evaluated against.
+type EvidencePolicySet struct {
+	Source    string   `json:"source"`
+	PolicyDir string   `json:"policy_dir,omitempty"`
+	PolicyIDs []string `json:"policy_ids"`
+	Complete  bool     `json:"complete"`
+}
+
+// EvidenceFile is a file in the bundle with its content hash.
+type EvidenceFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+var evidenceKeyMu sync.Mutex
+
+// evidenceKey returns the server's signing key. It comes from
+// CLOUDRIFT_EVIDENCE_KEY (a base64 Ed25519 seed) or is generated once and
+// kept in the data directory.
+func evidenceKey() (ed25519.PrivateKey, error) {
+	evidenceKeyMu.Lock()
+	defer evidenceKeyMu.Unlock()
+
+	encoded := os.Getenv("CLOUDRIFT_EVIDENCE_KEY")
+	keyPath := filepath.Join(dataDir(), "keys", "evidence.key")
+	if encoded == "" {
+		if data, err := os.ReadFile(keyPath); err == nil {
+			encoded = strings.TrimSpace(string(data))
+		}
+	}
+	if encoded == "" {
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, err
+		}
+		encoded = base64.StdEncoding.EncodeToString(seed)
+		if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(keyPath, []byte(encoded+"\n"), 0600); err != nil {
+			return nil, err
+		}
+	}
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("evidence key must be a base64-encoded %d-byte seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// evidenceKeyID is a short fingerprint of the public key.
+func evidenceKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// GET /api/scans/{id}/evidence — Download a signed evidence bundle (zip).
+func handleEvidenceBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rec, err := loadScan(r.PathValue("id"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	scan, err := rec.Scan()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key, err := evidenceKey()
+	if err != nil {
+		jsonError(w, "Signing key unavailable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	manifest := EvidenceManifest{
+		BundleID:   fmt.Sprintf("evidence-%s-%d", rec.ID, now.Unix()),
+		CreatedAt:  now,
+		ScanID:     rec.ID,
+		ScannedAt:  rec.CreatedAt,
+		Service:    scan.Service,
+		AccountID:  scan.AccountID,
+		Region:     scan.Region,
+		ConfigPath: rec.ConfigPath,
+		PolicySet:  EvidencePolicySet{Source: "embedded", PolicyIDs: []string{}},
+		Files:      []EvidenceFile{},
+		Algorithm:  "ed25519",
+		KeyID:      evidenceKeyID(key.Public().(ed25519.PublicKey)),
+	}
+	if rec.PolicyDir != "" {
+		manifest.PolicySet.Source = "custom"
+		manifest.PolicySet.PolicyDir = rec.PolicyDir
+	}
+	if rec.Inputs != nil && rec.Inputs.PolicyIDs != nil {
+		manifest.PolicySet.PolicyIDs = rec.Inputs.PolicyIDs
+		manifest.PolicySet.Complete = rec.Inputs.PolicyIDsComplete
+	} else {
+		// Scans stored before the evaluated set was recorded: only the
+		// policies with findings are known.
+		manifest.PolicySet.PolicyIDs = append([]string{}, slices.Sorted(maps.Keys(evaluatedPolicies(scan, nil)))...)
+	}
+
+	// Collect bundle contents: the scan itself plus the input snapshot.
+	files := map[string][]byte{}
+	var scanJSON bytes.Buffer
+	json.Indent(&scanJSON, rec.Result, "", "  ")
+	files["scan.json"] = scanJSON.Bytes()
+	if rec.Inputs != nil {
+		manifest.CLIVersion = rec.Inputs.CLIVersion
+		manifest.TerraformVersion = rec.Inputs.TerraformVersion
+		manifest.PlanPath = rec.Inputs.PlanPath
+		snapshotDir, _ := scanSnapshotDir(rec.ID)
+		for _, rel := range rec.Inputs.Files {
+			data, err := os.ReadFile(filepath.Join(snapshotDir, filepath.FromSlash(rel)))
+			if err != nil {
+				jsonError(w, "Scan input snapshot missing: "+rel, http.StatusInternalServerError)
+				return
+			}
+			// Snapshots taken before plans were redacted still hold the
+			// raw values.
+			if strings.HasPrefix(rel, "plan/") {
+				if redacted, paths, err := redactPlanJSON(data); err == nil && len(paths) > 0 {
+					data = redacted
+				}
+			}
+			files["inputs/"+rel] = data
+		}
+	}
+	versions, _ := json.MarshalIndent(map[string]string{
+		"cloudrift": manifest.CLIVersion,
+		"terraform": manifest.TerraformVersion,
+	}, "", "  ")
+	files["versions.json"] = versions
+	policySet, _ := json.MarshalIndent(manifest.PolicySet, "", "  ")
+	files["policy-set.json"] = policySet
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		manifest.Files = append(manifest.Files, EvidenceFile{
+			Path: name, SHA256: sha256Hex(files[name]), Size: len(files[name]),
+		})
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		jsonError(w, "Failed to build manifest: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifestJSON))
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, data []byte) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(data)
+		return err
+	}
+	files["manifest.json"] = manifestJSON
+	files["manifest.sig"] = []byte(signature + "\n")
+	for _, name := range append([]string{"manifest.json", "manifest.sig"}, names...) {
+		if err := add(name, files[name]); err != nil {
+			jsonError(w, "Failed to build bundle: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		jsonError(w, "Failed to build bundle: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, manifest.BundleID))
+	w.Write(buf.Bytes())
+}
+
+// POST /api/evidence/verify — Check an evidence bundle's signature and file
+// hashes. Accepts the zip as a multipart "file" field.
+func handleEvidenceVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 50*1024*1024)
+	if err := r.ParseMultipartForm(50 << 20); err != nil {
+		jsonError(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		jsonError(w, "Missing file field: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		jsonError(w, "Failed to read file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		jsonError(w, "Not a valid zip archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, err := evidenceKey()
+	if err != nil {
+		jsonError(w, "Signing key unavailable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contents := map[string][]byte{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			jsonError(w, "Failed to read "+f.Name+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(rc, 50*1024*1024))
+		rc.Close()
+		if err != nil {
+			jsonError(w, "Failed to read "+f.Name+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		contents[f.Name] = body
+	}
+
+	type fileCheck struct {
+		Path   string `json:"path"`
+		Status string `json:"status"`
+	}
+	result := struct {
+		Valid          bool        `json:"valid"`
+		SignatureValid bool        `json:"signature_valid"`
+		KeyID          string      `json:"key_id"`
+		BundleID       string      `json:"bundle_id,omitempty"`
+		ScanID         string      `json:"scan_id,omitempty"`
+		CreatedAt      *time.Time  `json:"created_at,omitempty"`
+		Files          []fileCheck `json:"files"`
+		Errors         []string    `json:"errors"`
+	}{
+		KeyID:  evidenceKeyID(key.Public().(ed25519.PublicKey)),
+		Files:  []fileCheck{},
+		Errors: []string{},
+	}
+
+	manifestJSON, hasManifest := contents["manifest.json"]
+	sigData, hasSig := contents["manifest.sig"]
+	var manifest EvidenceManifest
+	switch {
+	case !hasManifest:
+		result.Errors = append(result.Errors, "manifest.json is missing")
+	case !hasSig:
+		result.Errors = append(result.Errors, "manifest.sig is missing")
+	case json.Unmarshal(manifestJSON, &manifest) != nil:
+		result.Errors = append(result.Errors, "manifest.json is not valid JSON")
+	default:
+		result.BundleID = manifest.BundleID
+		result.ScanID = manifest.ScanID
+		result.CreatedAt = &manifest.CreatedAt
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+		if err == nil && ed25519.Verify(key.Public().(ed25519.PublicKey), manifestJSON, sig) {
+			result.SignatureValid = true
+		} else {
+			result.Errors = append(result.Errors, "manifest signature does not match this server's key")
+		}
+		if manifest.KeyID != result.KeyID {
+			result.Errors = append(result.Errors, fmt.Sprintf("bundle was signed with key %s, server key is %s", manifest.KeyID, result.KeyID))
+		}
+
+		listed := map[string]bool{"manifest.json": true, "manifest.sig": true}
+		for _, f := range manifest.Files {
+			listed[f.Path] = true
+			body, ok := contents[f.Path]
+			status := "ok"
+			switch {
+			case !ok:
+				status = "missing"
+			case sha256Hex(body) != f.SHA256:
+				status = "modified"
+			}
+			if status != "ok" {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s is %s", f.Path, status))
+			}
+			result.Files = append(result.Files, fileCheck{Path: f.Path, Status: status})
+		}
+		names := make([]string, 0, len(contents))
+		for name := range contents {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !listed[name] {
+				result.Files = append(result.Files, fileCheck{Path: name, Status: "unexpected"})
+				result.Errors = append(result.Errors, name+" is not listed in the manifest")
+			}
+		}
+	}
+	result.Valid = len(result.Errors) == 0
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ---------------------------------------------------------------------------
+// Outbound webhooks
+// ---------------------------------------------------------------------------
+
+// Webhook event types.
+const (
+	eventScanCompleted      = "scan.completed"
+	eventThresholdBreached  = "threshold.breached"
+	eventTerraformJobFailed = "terraform.job_failed"
+	eventWaiverExpired      = "waiver.expired"
+	eventPing               = "ping"
+)
+
+var webhookEvents = []string{eventScanCompleted, eventThresholdBreached, eventTerraformJobFailed, eventWaiverExpired}
+
+const (
+	webhooksFile           = "webhooks.json"
+	webhookMaxAttempts     = 5
+	webhookRetryBase       = 2 * time.Second
+	webhookHistoryLimit    = 500
+	webhookSignatureHeader = "X-Cloudrift-Signature"
+)
+
+// Webhook is a configured receiver for server events. An empty Events list
+// subscribes to every event. Thresholds of 0 are disabled.
+type Webhook struct {
+	ID                string    `json:"id"`
+	URL               string    `json:"url"`
+	Secret            string    `json:"secret,omitempty"`
+	Events            []string  `json:"events"`
+	DriftThreshold    int       `json:"drift_threshold"`
+	CriticalThreshold int       `json:"critical_threshold"`
+	Enabled           bool      `json:"enabled"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func (h Webhook) subscribed(event string) bool {
+	return event == eventPing || len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// redacted hides the signing secret in API responses.
+func (h Webhook) redacted() Webhook {
+	if h.Secret != "" {
+		h.Secret = "********"
+	}
+	return h
+}
+
+// WebhookDelivery records one event sent to one webhook, including retries.
+type WebhookDelivery struct {
+	ID          string           `json:"id"`
+	WebhookID   string           `json:"webhook_id"`
+	Event       string           `json:"event"`
+	URL         string           `json:"url"`
+	Status      string           `json:"status"`
+	Attempts    []WebhookAttempt `json:"attempts"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt time.Time        `json:"completed_at,omitempty"`
+}
+
+// WebhookAttempt is a single HTTP attempt of a delivery.
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+var (
+	webhookStore      = newRecordStore(webhooksFile, "Webhook", "webhooks", func(h Webhook) string { return h.ID })
+	webhookDeliveries []*WebhookDelivery
+	webhookDeliveryMu sync.Mutex
+	webhookHTTPClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+func validateWebhook(h *Webhook) error {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	for _, e := range h.Events {
+		if !slices.Contains(webhookEvents, e) {
+			return fmt.Errorf("unknown event %q (expected one of %s)", e, strings.Join(webhookEvents, ", "))
+		}
+	}
+	if h.DriftThreshold < 0 || h.CriticalThreshold < 0 {
+		return fmt.Errorf("thresholds must not be negative")
+	}
+	if h.Events == nil {
+		h.Events = []string{}
+	}
+	return nil
+}
+
+// GET/POST /api/webhooks — List or create webhooks.
+func handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		hooks, err := webhookStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load webhooks: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out := []Webhook{}
+		for _, h := range hooks {
+			out = append(out, h.redacted())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"webhooks": out, "events": webhookEvents})
+	case http.MethodPost:
+		hook := Webhook{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateWebhook(&hook); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hook.ID = fmt.Sprintf("wh-%d", time.Now().UnixNano())
+		hook.CreatedAt = time.Now().UTC()
+		webhookStore.add(w, hook, hook.redacted())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/webhooks/{id} — Read, replace or remove a webhook.
+func handleWebhook(w http.ResponseWriter, r *http.Request) {
+	view := func(h Webhook) interface{} { return h.redacted() }
+	webhookStore.serveRecord(w, r, view, func(old Webhook, updated *Webhook) (interface{}, error) {
+		if updated.Secret == "********" {
+			updated.Secret = old.Secret
+		}
+		updated.ID, updated.CreatedAt = old.ID, old.CreatedAt
+		if err := validateWebhook(updated); err != nil {
+			return nil, err
+		}
+		return updated.redacted(), nil
+	})
+}
+
+// POST /api/webhooks/{id}/test — Send a ping event to one webhook.
+func handleWebhookTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hooks, err := webhookStore.load()
+	if err != nil {
+		jsonError(w, "Failed to load webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idx := slices.IndexFunc(hooks, func(h Webhook) bool { return h.ID == r.PathValue("id") })
+	if idx < 0 {
+		jsonError(w, "Webhook not found: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	delivery := deliverWebhook(hooks[idx], eventPing, map[string]string{"message": "Cloudrift webhook test"}, 1)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// GET /api/webhooks/deliveries?webhook_id=<id> — Recent delivery history.
+func handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter := r.URL.Query().Get("webhook_id")
+	webhookDeliveryMu.Lock()
+	out := []WebhookDelivery{}
+	for i := len(webhookDeliveries) - 1; i >= 0; i-- {
+		d := webhookDeliveries[i]
+		if filter == "" || d.WebhookID == filter {
+			copied := *d
+			copied.Attempts = append([]WebhookAttempt{}, d.Attempts...)
+			out = append(out, copied)
+		}
+	}
+	webhookDeliveryMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"deliveries": out})
+}
+
+// dispatchEvent delivers an event to every enabled, subscribed webhook in
+// the background.
+func dispatchEvent(event string, data interface{}) {
+	hooks, err := webhookStore.load()
+	if err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+		return
+	}
+	for _, h := range hooks {
+		if h.Enabled && h.subscribed(event) {
+			go deliverWebhook(h, event, data, webhookMaxAttempts)
+		}
+	}
+}
+
+// notifyScanCompleted sends scan.completed to webhooks and chat channels and, when drift or critical
+// violations cross a webhook's threshold compared with the previous scan
+// of the same service, threshold.breached.
+func notifyScanCompleted(rec *StoredScan) {
+	summary := summarizeScan(rec)
+	dispatchEvent(eventScanCompleted, summary)
+	notifyChannels(eventScanCompleted, func(c NotificationChannel) chatMessage {
+		return scanChatMessage(rec, c.MaxViolations, c.uiURL())
+	})
+
+	var previous *ScanSummary
+	if scans, err := listScans(); err == nil {
+		for _, s := range scans {
+			if s.ID != rec.ID && s.Service == rec.Service && s.CreatedAt.Before(rec.CreatedAt) {
+				p := summarizeScan(s)
+				previous = &p
+				break
+			}
+		}
+	}
+
+	hooks, err := webhookStore.load()
+	if err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+		return
+	}
+	for _, h := range hooks {
+		if !h.Enabled || !h.subscribed(eventThresholdBreached) {
+			continue
+		}
+		check := func(metric string, threshold, value int, prev func(ScanSummary) int) {
+			if threshold == 0 || value < threshold {
+				return
+			}
+			data := map[string]interface{}{
+				"metric": metric, "threshold": threshold, "value": value, "scan": summary,
+			}
+			if previous != nil {
+				if prev(*previous) >= threshold {
+					return // already above the threshold
+				}
+				data["previous"] = prev(*previous)
+			}
+			go deliverWebhook(h, eventThresholdBreached, data, webhookMaxAttempts)
+		}
+		check("drift_count", h.DriftThreshold, summary.DriftCount,
+			func(s ScanSummary) int { return s.DriftCount })
+		check("critical_violations", h.CriticalThreshold, summary.CriticalViolations,
+			func(s ScanSummary) int { return s.CriticalViolations })
+	}
+}
+
+// signPayload returns the HMAC-SHA256 signature header value for body.
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook POSTs an event to a webhook, retrying with exponential
+// backoff on network errors, 429 and 5xx responses.
+func deliverWebhook(h Webhook, event string, data interface{}, maxAttempts int) WebhookDelivery {
+	delivery := &WebhookDelivery{
+		ID:        fmt.Sprintf("dlv-%d", time.Now().UnixNano()),
+		WebhookID: h.ID,
+		Event:     event,
+		URL:       h.URL,
+		Status:    "pending",
+		Attempts:  []WebhookAttempt{},
+		CreatedAt: time.Now().UTC(),
+	}
+	webhookDeliveryMu.Lock()
+	webhookDeliveries = append(webhookDeliveries, delivery)
+	if len(webhookDeliveries) > webhookHistoryLimit {
+		webhookDeliveries = webhookDeliveries[len(webhookDeliveries)-webhookHistoryLimit:]
+	}
+	webhookDeliveryMu.Unlock()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":         delivery.ID,
+		"event":      event,
+		"created_at": delivery.CreatedAt,
+		"data":       data,
+	})
+	headers := map[string]string{
+		"X-Cloudrift-Event":    event,
+		"X-Cloudrift-Delivery": delivery.ID,
+	}
+	if h.Secret != "" {
+		headers[webhookSignatureHeader] = signPayload(h.Secret, body)
+	}
+
+	status := "failed"
+	if postWithRetry(h.URL, body, headers, maxAttempts, func(a WebhookAttempt) {
+		webhookDeliveryMu.Lock()
+		delivery.Attempts = append(delivery.Attempts, a)
+		webhookDeliveryMu.Unlock()
+	}) {
+		status = "delivered"
+	}
+
+	webhookDeliveryMu.Lock()
+	delivery.Status = status
+	delivery.CompletedAt = time.Now().UTC()
+	copied := *delivery
+	webhookDeliveryMu.Unlock()
+	if status != "delivered" {
+		log.Printf("Webhook %s delivery %s (%s) failed after %d attempt(s)", h.ID, delivery.ID, event, len(copied.Attempts))
+	}
+	return copied
+}
+
+// postWithRetry POSTs a JSON body, retrying with exponential backoff on
+// network errors, 429 and 5xx responses. Each attempt is reported to
+// onAttempt. It returns whether a 2xx response was received.
+func postWithRetry(target string, body []byte, headers map[string]string, maxAttempts int, onAttempt func(WebhookAttempt)) bool {
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(webhookRetryBase << (attempt - 2))
+		}
+		start := time.Now()
+		result := WebhookAttempt{At: start.UTC()}
+		delivered, retry := false, true
+
+		req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "Cloudrift-Webhook/1.0")
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			var resp *http.Response
+			resp, err = webhookHTTPClient.Do(req)
+			if err == nil {
+				io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+				resp.Body.Close()
+				result.StatusCode = resp.StatusCode
+				switch {
+				case resp.StatusCode >= 200 && resp.StatusCode < 300:
+					delivered, retry = true, false
+				case resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500:
+					retry = false
+				}
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.DurationMs = time.Since(start).Milliseconds()
+		onAttempt(result)
+		if delivered {
+			return true
+		}
+		if !retry {
+			return false
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Chat notification channels
+// ---------------------------------------------------------------------------
+
+const notificationsFile = "notifications.json"
+
+// Chat formats a notification channel can render.
+var chatFormats = []string{"slack", "teams", "discord"}
+
+// Events a notification channel can subscribe to.
+var notificationEvents = []string{eventScanCompleted, eventWaiverExpired}
+
+// NotificationChannel posts human-readable messages to a chat app's
+// incoming webhook. An empty Events list subscribes to every event.
+type NotificationChannel struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Format        string    `json:"format"`
+	WebhookURL    string    `json:"webhook_url"`
+	Events        []string  `json:"events"`
+	MaxViolations int       `json:"max_violations"`
+	UIURL         string    `json:"ui_url,omitempty"`
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (c NotificationChannel) subscribed(event string) bool {
+	return len(c.Events) == 0 || slices.Contains(c.Events, event)
+}
+
+// redacted hides the webhook URL path, which acts as a credential for most
+// chat apps.
+func (c NotificationChannel) redacted() NotificationChannel {
+	if u, err := url.Parse(c.WebhookURL); err == nil && u.Host != "" {
+		c.WebhookURL = u.Scheme + "://" + u.Host + "/********"
+	}
+	return c
+}
+
+// uiURL is the base URL links in messages point to.
+func (c NotificationChannel) uiURL() string {
+	base := c.UIURL
+	if base == "" {
+		base = os.Getenv("CLOUDRIFT_UI_URL")
+	}
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// chatMessage is a format-neutral notification rendered per chat app.
+type chatMessage struct {
+	Title     string
+	Summary   string
+	Fields    []chatField
+	ListTitle string
+	Items     []string
+	Link      string
+	LinkLabel string
+	Level     string // "danger", "warning" or "good"
+}
+
+type chatField struct {
+	Label string
+	Value string
+}
+
+var notificationStore = newRecordStore(notificationsFile, "Notification channel", "notification channels",
+	func(c NotificationChannel) string { return c.ID })
+
+func validateNotificationChannel(c *NotificationChannel) error {
+	if !slices.Contains(chatFormats, c.Format) {
+		return fmt.Errorf("format must be one of %s", strings.Join(chatFormats, ", "))
+	}
+	u, err := url.Parse(c.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an absolute http(s) URL")
+	}
+	for _, e := range c.Events {
+		if !slices.Contains(notificationEvents, e) {
+			return fmt.Errorf("unknown event %q (expected one of %s)", e, strings.Join(notificationEvents, ", "))
+		}
+	}
+	if c.MaxViolations <= 0 {
+		c.MaxViolations = 5
+	}
+	if c.Events == nil {
+		c.Events = []string{}
+	}
+	return nil
+}
+
+// scanChatMessage summarizes a scan for chat: where it ran, drift and the
+// most severe critical violations.
+func scanChatMessage(rec *StoredScan, maxViolations int, uiURL string) chatMessage {
+	summary := summarizeScan(rec)
+	msg := chatMessage{
+		Title: fmt.Sprintf("Cloudrift scan: %s", strings.ToUpper(summary.Service)),
+		Fields: []chatField{
+			{"Service", strings.ToUpper(summary.Service)},
+			{"Account", orDash(summary.AccountID)},
+			{"Region", orDash(summary.Region)},
+			{"Drifted resources", fmt.Sprintf("%d of %d", summary.DriftCount, summary.TotalResources)},
+			{"Violations", fmt.Sprintf("%d (%d critical)", summary.Violations, summary.CriticalViolations)},
+			{"Compliance", fmt.Sprintf("%.1f%%", summary.CompliancePercentage)},
+		},
+		ListTitle: "Top critical violations",
+		Link:      uiURL + "/#/dashboard",
+		LinkLabel: "Open in Cloudrift",
+		Level:     "good",
+	}
+	switch {
+	case summary.CriticalViolations > 0:
+		msg.Level = "danger"
+	case summary.DriftCount > 0 || summary.Violations > 0:
+		msg.Level = "warning"
+	}
+	msg.Summary = fmt.Sprintf("%d drifted resource(s), %d violation(s), %d critical.",
+		summary.DriftCount, summary.Violations, summary.CriticalViolations)
+
+	if scan, err := rec.Scan(); err == nil && scan.PolicyResult != nil {
+		for _, v := range scan.PolicyResult.Violations {
+			if !strings.EqualFold(v.Severity, "critical") {
+				continue
+			}
+			if len(msg.Items) == maxViolations {
+				msg.Items = append(msg.Items, fmt.Sprintf("…and %d more", summary.CriticalViolations-maxViolations))
+				break
+			}
+			msg.Items = append(msg.Items, fmt.Sprintf("`%s` %s — %s", v.PolicyID, v.PolicyName, v.ResourceAddress))
+		}
+	}
+	if len(msg.Items) == 0 {
+		msg.Items = []string{"No critical violations"}
+	}
+	return msg
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+// slackEscaper escapes the characters Slack's mrkdwn reserves for links and
+// mentions, so names such as "a<b>" or "R&D" are shown as written.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// renderChatMessage builds the request body for a chat app's incoming
+// webhook: Slack Block Kit, a Teams Adaptive Card, or a Discord embed.
+func renderChatMessage(format string, msg chatMessage) map[string]interface{} {
+	switch format {
+	case "teams":
+		facts := []map[string]string{}
+		for _, f := range msg.Fields {
+			facts = append(facts, map[string]string{"title": f.Label, "value": f.Value})
+		}
+		color := map[string]string{"danger": "attention", "warning": "warning", "good": "good"}[msg.Level]
+		body := []map[string]interface{}{
+			{"type": "TextBlock", "size": "Large", "weight": "Bolder", "text": msg.Title, "wrap": true, "color": color},
+			{"type": "TextBlock", "text": msg.Summary, "wrap": true},
+			{"type": "FactSet", "facts": facts},
+		}
+		if len(msg.Items) > 0 {
+			body = append(body,
+				map[string]interface{}{"type": "TextBlock", "text": msg.ListTitle, "weight": "Bolder", "wrap": true},
+				map[string]interface{}{"type": "TextBlock", "text": "- " + strings.Join(msg.Items, "\n- "), "wrap": true},
+			)
+		}
+		card := map[string]interface{}{
+			"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+			"type":    "AdaptiveCard",
+			"version": "1.4",
+			"body":    body,
+		}
+		if msg.Link != "" {
+			card["actions"] = []map[string]string{{"type": "Action.OpenUrl", "title": msg.LinkLabel, "url": msg.Link}}
+		}
+		return map[string]interface{}{
+			"type": "message",
+			"attachments": []map[string]interface{}{
+				{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+			},
+		}
+	case "discord":
+		fields := []map[string]interface{}{}
+		for _, f := range msg.Fields {
+			fields = append(fields, map[string]interface{}{"name": f.Label, "value": f.Value, "inline": true})
+		}
+		description := msg.Summary
+		if len(msg.Items) > 0 {
+			description += "\n\n**" + msg.ListTitle + "**\n• " + strings.Join(msg.Items, "\n• ")
+		}
+		color := map[string]int{"danger": 0xC62828, "warning": 0xF9A825, "good": 0x2E7D32}[msg.Level]
+		embed := map[string]interface{}{
+			"title":       msg.Title,
+			"description": description,
+			"color":       color,
+			"fields":      fields,
+			"footer":      map[string]string{"text": "Cloudrift"},
+		}
+		if msg.Link != "" {
+			embed["url"] = msg.Link
+		}
+		return map[string]interface{}{"embeds": []interface{}{embed}}
+	default: // slack
+		esc := slackEscaper.Replace
+		fields := []map[string]string{}
+		for _, f := range msg.Fields {
+			fields = append(fields, map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", esc(f.Label), esc(f.Value))})
+		}
+		blocks := []map[string]interface{}{
+			{"type": "header", "text": map[string]string{"type": "plain_text", "text": msg.Title}},
+			{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": esc(msg.Summary)}},
+			{"type": "section", "fields": fields},
+		}
+		if len(msg.Items) > 0 {
+			blocks = append(blocks, map[string]interface{}{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n• %s", esc(msg.ListTitle), esc(strings.Join(msg.Items, "\n• ")))},
+			})
+		}
+		if msg.Link != "" {
+			blocks = append(blocks, map[string]interface{}{
+				"type": "actions",
+				"elements": []map[string]interface{}{{
+					"type": "button",
+					"text": map[string]string{"type": "plain_text", "text": msg.LinkLabel},
+					"url":  msg.Link,
+				}},
+			})
+		}
+		return map[string]interface{}{"text": esc(msg.Title + ": " + msg.Summary), "blocks": blocks}
+	}
+}
+
+// sendChatNotification posts a message to one channel and returns the attempts.
+func sendChatNotification(c NotificationChannel, msg chatMessage, maxAttempts int) ([]WebhookAttempt, bool) {
+	body, _ := json.Marshal(renderChatMessage(c.Format, msg))
+	attempts := []WebhookAttempt{}
+	ok := postWithRetry(c.WebhookURL, body, nil, maxAttempts, func(a WebhookAttempt) {
+		attempts = append(attempts, a)
+	})
+	if !ok {
+		log.Printf("Notification channel %s (%s) failed after %d attempt(s)", c.ID, c.Format, len(attempts))
+	}
+	return attempts, ok
+}
+
+// notifyChannels sends a message built per channel to every enabled channel
+// subscribed to event, in the background.
+func notifyChannels(event string, build func(c NotificationChannel) chatMessage) {
+	channels, err := notificationStore.load()
+	if err != nil {
+		log.Printf("Failed to load notification channels: %v", err)
+		return
+	}
+	for _, c := range channels {
+		if c.Enabled && c.subscribed(event) {
+			go sendChatNotification(c, build(c), webhookMaxAttempts)
+		}
+	}
+}
+
+// GET/POST /api/notifications — List or create notification channels.
+func handleNotifications(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		channels, err := notificationStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load notification channels: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out := []NotificationChannel{}
+		for _, c := range channels {
+			out = append(out, c.redacted())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"channels": out, "formats": chatFormats})
+	case http.MethodPost:
+		channel := NotificationChannel{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&channel); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateNotificationChannel(&channel); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		channel.ID = fmt.Sprintf("nc-%d", time.Now().UnixNano())
+		channel.CreatedAt = time.Now().UTC()
+		notificationStore.add(w, channel, channel.redacted())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/notifications/{id} — Read, replace or remove a channel.
+func handleNotification(w http.ResponseWriter, r *http.Request) {
+	view := func(c NotificationChannel) interface{} { return c.redacted() }
+	notificationStore.serveRecord(w, r, view, func(old NotificationChannel, updated *NotificationChannel) (interface{}, error) {
+		if updated.WebhookURL == old.redacted().WebhookURL {
+			updated.WebhookURL = old.WebhookURL
+		}
+		updated.ID, updated.CreatedAt = old.ID, old.CreatedAt
+		if err := validateNotificationChannel(updated); err != nil {
+			return nil, err
+		}
+		return updated.redacted(), nil
+	})
+}
+
+// latestOrNamedScan loads the scan given by id, or the most recent scan.
+func latestOrNamedScan(id string) (*StoredScan, error) {
+	if id != "" {
+		return loadScan(id)
+	}
+	scans, err := listScans()
+	if err != nil {
+		return nil, err
+	}
+	if len(scans) == 0 {
+		return nil, fmt.Errorf("no scans available")
+	}
+	return scans[0], nil
+}
+
+// POST /api/notifications/{id}/test?scan=<id> — Send a scan summary (the
+// latest scan by default) to one channel with a single attempt.
+func handleNotificationTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	channels, err := notificationStore.load()
+	if err != nil {
+		jsonError(w, "Failed to load notification channels: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idx := slices.IndexFunc(channels, func(c NotificationChannel) bool { return c.ID == r.PathValue("id") })
+	if idx < 0 {
+		jsonError(w, "Notification channel not found: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	rec, err := latestOrNamedScan(r.URL.Query().Get("scan"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	c := channels[idx]
+	attempts, ok := sendChatNotification(c, scanChatMessage(rec, c.MaxViolations, c.uiURL()), 1)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"delivered": ok,
+		"attempts":  attempts,
+	})
+}
+
+// GET /api/notifications/preview?format=slack&scan=<id> — Render a scan
+// summary without sending it.
+func handleNotificationPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	channel := NotificationChannel{Format: r.URL.Query().Get("format"), MaxViolations: 5}
+	if channel.Format == "" {
+		channel.Format = "slack"
+	}
+	if !slices.Contains(chatFormats, channel.Format) {
+		jsonError(w, "format must be one of "+strings.Join(chatFormats, ", "), http.StatusBadRequest)
+		return
+	}
+	rec, err := latestOrNamedScan(r.URL.Query().Get("scan"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(renderChatMessage(channel.Format, scanChatMessage(rec, channel.MaxViolations, channel.uiURL())))
+}
+
+// ---------------------------------------------------------------------------
+// Scan post-processing
+// ---------------------------------------------------------------------------
+
+// processScanResult applies the server-side rules (waivers etc.) to the
+// CLI's JSON output before it is stored and returned, and records the
+// policies the scan evaluated in inputs. Output that does not parse, or that
+// no rule changed, is passed through unchanged.
+func processScanResult(raw string, inputs *ScanInputs, catalog []PolicyCatalogEntry) string {
+	var scan ScanResult
+	if err := json.Unmarshal([]byte(raw), &scan); err != nil {
+		log.Printf("Failed to parse scan result, returning it unprocessed: %v", err)
+		inputs.PolicyIDsComplete = false
+		return raw
+	}
+	// Finding lists the CLI left out are reported as empty, never null.
+	if pr := scan.PolicyResult; pr != nil {
+		if pr.Violations == nil {
+			pr.Violations = []PolicyViolation{}
+		}
+		if pr.Warnings == nil {
+			pr.Warnings = []PolicyViolation{}
+		}
+	}
+	before, err := json.Marshal(scan)
+	if err != nil {
+		log.Printf("Failed to encode scan result, returning it unprocessed: %v", err)
+		return raw
+	}
+	evaluated := recordEvaluatedPolicies(&scan, inputs, catalog)
+	if err := applySeverityOverrides(&scan); err != nil {
+		log.Printf("Failed to apply severity overrides: %v", err)
+	}
+	if err := applyWaivers(&scan, time.Now()); err != nil {
+		log.Printf("Failed to apply waivers: %v", err)
+	}
+	if err := scoreCustomFrameworks(&scan, evaluated); err != nil {
+		log.Printf("Failed to score custom frameworks: %v", err)
+	}
+	if err := applyDriftRules(&scan); err != nil {
+		log.Printf("Failed to apply drift ignore rules: %v", err)
+	}
+	if err := applyBaselines(&scan); err != nil {
+		log.Printf("Failed to apply drift baselines: %v", err)
+	}
+	data, err := json.Marshal(scan)
+	if err != nil {
+		log.Printf("Failed to encode processed scan result: %v", err)
+		return raw
+	}
+	if bytes.Equal(data, before) {
+		return raw
+	}
+	return string(mergeJSON(json.RawMessage(raw), data))
+}
+
+// mergeJSON lays processed over raw so that fields of the CLI's output the
+// server does not model survive processing. Objects are merged key by key;
+// other values, arrays included, are taken from processed. A null that raw
+// did not have is left out.
+func mergeJSON(raw, processed json.RawMessage) json.RawMessage {
+	var rawObj, procObj map[string]json.RawMessage
+	if json.Unmarshal(raw, &rawObj) != nil || json.Unmarshal(processed, &procObj) != nil || rawObj == nil || procObj == nil {
+		return processed
+	}
+	for k, v := range procObj {
+		old, ok := rawObj[k]
+		switch {
+		case ok:
+			rawObj[k] = mergeJSON(old, v)
+		case string(v) != "null":
+			rawObj[k] = v
+		}
+	}
+	merged, err := json.Marshal(rawObj)
+	if err != nil {
+		return processed
+	}
+	return merged
+}
+
+// globMatch reports whether s matches pattern, where '*' matches any run of
+// characters and '?' matches exactly one. Unlike path.Match, '[' and '/'
+// have no special meaning, so resource addresses such as
+// aws_s3_bucket.logs["eu"] and module paths can be matched literally.
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// rescorePolicies updates the pass/fail counts and compliance scores after
+// findings moved in or out of the violations list. before is the list of
+// violations prior to the change; a policy fails while it has a violation.
+// A policy that stopped failing because its violations were waived is taken
+// out of the totals rather than counted as passing.
+func rescorePolicies(pr *PolicyOutput, before []PolicyViolation) {
+	failing := func(violations []PolicyViolation) map[string]PolicyViolation {
+		m := map[string]PolicyViolation{}
+		for _, v := range violations {
+			m[v.PolicyID] = v
+		}
+		return m
+	}
+	was, is, waived := failing(before), failing(pr.Violations), failing(pr.Waived)
+	for id, v := range was {
+		if _, ok := is[id]; ok || pr.Failed == 0 {
+			continue
+		}
+		pr.Failed--
+		if _, ok := waived[id]; ok {
+			shiftPolicyScore(pr.Compliance, v, 0, -1)
+			continue
+		}
+		pr.Passed++
+		shiftPolicyScore(pr.Compliance, v, 1, -1)
+	}
+	for id, v := range is {
+		if _, ok := was[id]; !ok && pr.Passed > 0 {
+			pr.Passed--
+			pr.Failed++
+			shiftPolicyScore(pr.Compliance, v, -1, 1)
+		}
+	}
+}
+
+// shiftPolicyScore adds the given deltas to the passing and failing counts
+// of the overall score and of the policy's category and frameworks.
+func shiftPolicyScore(c *Compliance, v PolicyViolation, passed, failed int) {
+	if c == nil {
+		return
+	}
+	adjust := func(m map[string]ComplianceEntry, key string) {
+		if e, ok := m[key]; ok && e.Passed+passed >= 0 && e.Failed+failed >= 0 {
+			m[key] = complianceScore(e.Passed+passed, e.Failed+failed)
+		}
+	}
+	adjust(c.Categories, v.Category)
+	for _, fw := range v.Frameworks {
+		adjust(c.Frameworks, fw)
+	}
+	if c.PassingPolicies+passed >= 0 && c.FailingPolicies+failed >= 0 && c.TotalPolicies+passed+failed >= 0 {
+		c.PassingPolicies += passed
+		c.FailingPolicies += failed
+		c.TotalPolicies += passed + failed
+		c.OverallPercentage = complianceScore(c.PassingPolicies, c.FailingPolicies).Percentage
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Custom compliance frameworks
+// ---------------------------------------------------------------------------
+
+const frameworksFile = "frameworks.json"
+
+// Framework is a user-defined compliance framework, such as CIS AWS or an
+// internal standard, expressed as controls mapped to policy IDs.
+type Framework struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Version     string             `json:"version,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Controls    []FrameworkControl `json:"controls"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// FrameworkControl is satisfied when none of its policies has a violation.
+// It does not apply to a scan that evaluated none of its policies.
+type FrameworkControl struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Policies []string `json:"policies"`
+}
+
+// FrameworkScore is a custom framework's score for one scan.
+type FrameworkScore struct {
+	Name          string         `json:"name"`
+	Percentage    float64        `json:"percentage"`
+	Passed        int            `json:"passed"`
+	Failed        int            `json:"failed"`
+	Total         int            `json:"total"`
+	NotApplicable int            `json:"not_applicable"`
+	Controls      []ControlScore `json:"controls"`
+}
+
+// ControlScore is the status of one control in a scan.
+type ControlScore struct {
+	ID              string   `json:"id"`
+	Title           string   `json:"title"`
+	Status          string   `json:"status"` // "pass", "fail" or "not_applicable"
+	Policies        []string `json:"policies"`
+	FailingPolicies []string `json:"failing_policies"`
+	NotEvaluated    []string `json:"not_evaluated"`
+	Violations      int      `json:"violations"`
+}
+
+var frameworkStore = newRecordStore(frameworksFile, "Framework", "frameworks", func(f Framework) string { return f.ID })
+
+func validateFramework(f *Framework) error {
+	f.ID = strings.ToLower(strings.TrimSpace(f.ID))
+	if !validPolicyName(f.ID) {
+		return fmt.Errorf("id must contain only letters, digits, '-', '_' and '.'")
+	}
+	if slices.ContainsFunc(complianceFrameworks, func(fw struct{ Key, Label string }) bool { return fw.Key == f.ID }) {
+		return fmt.Errorf("id %q is a built-in framework", f.ID)
+	}
+	if strings.TrimSpace(f.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(f.Controls) == 0 {
+		return fmt.Errorf("at least one control is required")
+	}
+	seen := map[string]bool{}
+	for i, c := range f.Controls {
+		c.ID = strings.TrimSpace(c.ID)
+		if c.ID == "" {
+			return fmt.Errorf("controls[%d]: id is required", i)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("controls[%d]: duplicate control id %q", i, c.ID)
+		}
+		seen[c.ID] = true
+		if len(c.Policies) == 0 {
+			return fmt.Errorf("control %s: at least one policy is required", c.ID)
+		}
+		f.Controls[i].ID = c.ID
+	}
+	return nil
+}
+
+// unknownPolicies lists policy IDs a framework maps that are not in the
+// policy catalog. They are allowed, since custom policies may follow later.
+// Nothing is reported while the catalog is unavailable (nil).
+func (f Framework) unknownPolicies(catalog []PolicyCatalogEntry) []string {
+	unknown := []string{}
+	if catalog == nil {
+		return unknown
+	}
+	known := map[string]bool{}
+	for _, p := range catalog {
+		known[p.ID] = true
+	}
+	for _, c := range f.Controls {
+		for _, id := range c.Policies {
+			if !known[id] && !slices.Contains(unknown, id) {
+				unknown = append(unknown, id)
+			}
+		}
+	}
+	return unknown
+}
+
+// frameworkCatalog loads the policy catalog for unknownPolicies, or nil if
+// the CLI cannot list its policies. It runs the CLI, so callers load it
+// before taking the framework store lock.
+func frameworkCatalog() []PolicyCatalogEntry {
+	catalog, err := policyCatalog()
+	if err != nil {
+		return nil
+	}
+	return catalog
+}
+
+// evaluatedPolicies returns the IDs of the policies a scan evaluated: every
+// policy with a finding, plus the CLI catalog's policies for the scan's
+// service. Without the CLI catalog only policies with findings are known.
+func evaluatedPolicies(scan *ScanResult, catalog []PolicyCatalogEntry) map[string]bool {
+	evaluated := map[string]bool{}
+	if scan.PolicyResult == nil {
+		return evaluated
+	}
+	for _, list := range [][]PolicyViolation{scan.PolicyResult.Violations, scan.PolicyResult.Warnings, scan.PolicyResult.Waived} {
+		for _, v := range list {
+			evaluated[v.PolicyID] = true
+		}
+	}
+	for _, p := range catalog {
+		if p.Source == "cli" && (strings.EqualFold(p.Service, scan.Service) || strings.EqualFold(p.Service, "all")) {
+			evaluated[p.ID] = true
+		}
+	}
+	return evaluated
+}
+
+// score evaluates the framework against a scan's violations. A control
+// fails when any of its policies has a violation, passes when at least one
+// of its policies was evaluated without one, and is not applicable when the
+// scan evaluated none of them. Not applicable controls are left out of the
+// percentage.
+func (f Framework) score(pr *PolicyOutput, evaluated map[string]bool) FrameworkScore {
+	violations := map[string]int{}
+	if pr != nil {
+		for _, v := range pr.Violations {
+			violations[v.PolicyID]++
+		}
+	}
+	fs := FrameworkScore{Name: f.Name, Controls: []ControlScore{}}
+	for _, c := range f.Controls {
+		cs := ControlScore{ID: c.ID, Title: c.Title, Status: "pass", Policies: c.Policies, FailingPolicies: []string{}, NotEvaluated: []string{}}
+		for _, id := range c.Policies {
+			if n := violations[id]; n > 0 {
+				cs.FailingPolicies = append(cs.FailingPolicies, id)
+				cs.Violations += n
+			} else if !evaluated[id] {
+				cs.NotEvaluated = append(cs.NotEvaluated, id)
+			}
+		}
+		switch {
+		case len(cs.FailingPolicies) > 0:
+			cs.Status = "fail"
+			fs.Failed++
+		case len(cs.NotEvaluated) == len(c.Policies):
+			cs.Status = "not_applicable"
+			fs.NotApplicable++
+		default:
+			fs.Passed++
+		}
+		fs.Controls = append(fs.Controls, cs)
+	}
+	entry := complianceScore(fs.Passed, fs.Failed)
+	fs.Percentage, fs.Total = entry.Percentage, entry.Total
+	return fs
+}
+
+// recordEvaluatedPolicies returns the policies a scan evaluated: its
+// findings, the catalog's CLI policies for its service and the custom
+// policies snapshotScanInputs found in the policy dir. The set is stored in
+// inputs, so /api/frameworks/{id}/score scores stored scans the same way.
+func recordEvaluatedPolicies(scan *ScanResult, inputs *ScanInputs, catalog []PolicyCatalogEntry) map[string]bool {
+	evaluated := evaluatedPolicies(scan, catalog)
+	for _, id := range inputs.PolicyIDs {
+		evaluated[id] = true
+	}
+	if scan.PolicyResult == nil {
+		inputs.PolicyIDsComplete = false
+		return evaluated
+	}
+	inputs.PolicyIDs = slices.Sorted(maps.Keys(evaluated))
+	return evaluated
+}
+
+// scoreCustomFrameworks adds the score of every custom framework to the
+// scan's compliance next to the CLI's frameworks.
+func scoreCustomFrameworks(scan *ScanResult, evaluated map[string]bool) error {
+	if scan.PolicyResult == nil || scan.PolicyResult.Compliance == nil {
+		return nil
+	}
+	frameworks, err := frameworkStore.load()
+	if err != nil || len(frameworks) == 0 {
+		return err
+	}
+	scores := map[string]FrameworkScore{}
+	for _, f := range frameworks {
+		scores[f.ID] = f.score(scan.PolicyResult, evaluated)
+	}
+	scan.PolicyResult.Compliance.CustomFrameworks = scores
+	return nil
+}
+
+// GET/POST /api/frameworks — List built-in and custom frameworks or create
+// a custom one.
+func handleFrameworks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		frameworks, err := frameworkStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load frameworks: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		builtin := map[string]string{}
+		for _, fw := range complianceFrameworks {
+			builtin[fw.Key] = fw.Label
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"builtin": builtin, "frameworks": frameworks})
+	case http.MethodPost:
+		var f Framework
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateFramework(&f); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.CreatedAt = time.Now().UTC()
+		f.UpdatedAt = f.CreatedAt
+		catalog := frameworkCatalog()
+		frameworkStore.add(w, f, map[string]interface{}{"framework": f, "unknown_policies": f.unknownPolicies(catalog)})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/frameworks/{id} — Read, replace or remove a custom
+// framework.
+func handleFramework(w http.ResponseWriter, r *http.Request) {
+	var catalog []PolicyCatalogEntry
+	if r.Method == http.MethodPut {
+		catalog = frameworkCatalog()
+	}
+	view := func(f Framework) interface{} { return f }
+	frameworkStore.serveRecord(w, r, view, func(old Framework, updated *Framework) (interface{}, error) {
+		updated.ID, updated.CreatedAt = old.ID, old.CreatedAt
+		if err := validateFramework(updated); err != nil {
+			return nil, err
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		return map[string]interface{}{"framework": *updated, "unknown_policies": updated.unknownPolicies(catalog)}, nil
+	})
+}
+
+// GET /api/frameworks/{id}/score?scan=<id> — Score a custom framework
+// against a stored scan (the latest by default), including scans taken
+// before the framework was defined.
+func handleFrameworkScore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	frameworks, err := frameworkStore.load()
+	if err != nil {
+		jsonError(w, "Failed to load frameworks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idx := slices.IndexFunc(frameworks, func(f Framework) bool { return f.ID == r.PathValue("id") })
+	if idx < 0 {
+		jsonError(w, "Framework not found: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	rec, err := latestOrNamedScan(r.URL.Query().Get("scan"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	scan, err := rec.Scan()
+	if err != nil {
+		jsonError(w, "Failed to read scan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var evaluated map[string]bool
+	if rec.Inputs != nil && rec.Inputs.PolicyIDs != nil {
+		evaluated = map[string]bool{}
+		for _, id := range rec.Inputs.PolicyIDs {
+			evaluated[id] = true
+		}
+	} else {
+		catalog, _ := policyCatalog()
+		evaluated = evaluatedPolicies(scan, catalog)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"framework": frameworks[idx].ID,
+		"scan_id":   rec.ID,
+		"score":     frameworks[idx].score(scan.PolicyResult, evaluated),
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Severity overrides
+// ---------------------------------------------------------------------------
+
+const severityOverridesFile = "severity_overrides.json"
+
+// severityOverrideStore holds the organization's severity per policy ID.
+var severityOverrideStore = &dataStore[map[string]string]{
+	file:  severityOverridesFile,
+	name:  "severity overrides",
+	empty: func() map[string]string { return map[string]string{} },
+}
+
+func validSeverity(severity string) bool {
+	return slices.Contains(severityOrder, severity)
+}
+
+// applySeverityOverrides replaces the severity of findings whose policy has
+// an override and records the CLI's value in original_severity. Raising a
+// finding to critical or high makes it a blocking violation; lowering it to
+// low or info makes it an advisory warning; medium keeps the CLI's choice.
+// Pass/fail counts and compliance scores follow the moved findings.
+func applySeverityOverrides(scan *ScanResult) error {
+	if scan.PolicyResult == nil {
+		return nil
+	}
+	overrides, err := severityOverrideStore.load()
+	if err != nil || len(overrides) == 0 {
+		return err
+	}
+	pr := scan.PolicyResult
+	violations, warnings := []PolicyViolation{}, []PolicyViolation{}
+	place := func(v PolicyViolation, blocking bool) {
+		if sev, ok := overrides[v.PolicyID]; ok && !strings.EqualFold(sev, v.Severity) {
+			v.OriginalSeverity, v.Severity = v.Severity, sev
+			switch {
+			case severityRank(sev) <= severityRank("high"):
+				blocking = true
+			case severityRank(sev) >= severityRank("low"):
+				blocking = false
+			}
+		}
+		if blocking {
+			violations = append(violations, v)
+		} else {
+			warnings = append(warnings, v)
+		}
+	}
+	for _, v := range pr.Violations {
+		place(v, true)
+	}
+	for _, v := range pr.Warnings {
+		place(v, false)
+	}
+	before := pr.Violations
+	pr.Violations, pr.Warnings = violations, warnings
+	rescorePolicies(pr, before)
+	return nil
+}
+
+// GET/PUT /api/severity-overrides — Read or replace the whole override map.
+func handleSeverityOverrides(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		overrides, err := severityOverrideStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load severity overrides: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"overrides": overrides, "severities": severityOrder})
+	case http.MethodPut:
+		var req struct {
+			Overrides map[string]string `json:"overrides"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		overrides := map[string]string{}
+		for id, sev := range req.Overrides {
+			id, sev = strings.TrimSpace(id), strings.ToLower(strings.TrimSpace(sev))
+			if id == "" || !validSeverity(sev) {
+				jsonError(w, fmt.Sprintf("invalid override %q: %q (severity must be one of %s)", id, sev, strings.Join(severityOrder, ", ")), http.StatusBadRequest)
+				return
+			}
+			overrides[id] = sev
+		}
+		severityOverrideStore.update(w, func(v *map[string]string) (interface{}, int, bool) {
+			*v = overrides
+			return map[string]interface{}{"overrides": overrides}, http.StatusOK, true
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PUT/DELETE /api/severity-overrides/{policy_id} — Set or clear the
+// override of one policy.
+func handleSeverityOverride(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("policy_id")
+	severityOverrideStore.update(w, func(v *map[string]string) (interface{}, int, bool) {
+		overrides := *v
+		switch r.Method {
+		case http.MethodPut:
+			var req struct {
+				Severity string `json:"severity"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return nil, 0, false
+			}
+			sev := strings.ToLower(strings.TrimSpace(req.Severity))
+			if !validSeverity(sev) {
+				jsonError(w, "severity must be one of "+strings.Join(severityOrder, ", "), http.StatusBadRequest)
+				return nil, 0, false
+			}
+			overrides[id] = sev
+		case http.MethodDelete:
+			if _, ok := overrides[id]; !ok {
+				jsonError(w, "No severity override for "+id, http.StatusNotFound)
+				return nil, 0, false
+			}
+			delete(overrides, id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return nil, 0, false
+		}
+		return map[string]interface{}{"overrides": overrides}, http.StatusOK, true
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Policy waivers
+// ---------------------------------------------------------------------------
+
+const waiversFile = "waivers.json"
+
+// Waiver suppresses findings of a policy on matching resources until it
+// expires. PolicyID and ResourceAddress accept '*' and '?' wildcards.
+type Waiver struct {
+	ID              string     `json:"id"`
+	PolicyID        string     `json:"policy_id"`
+	ResourceAddress string     `json:"resource_address"`
+	Reason          string     `json:"reason"`
+	Owner           string     `json:"owner"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiryNotified  *time.Time `json:"expiry_notified_at,omitempty"`
+	Status          string     `json:"status"`
+}
+
+func (wv Waiver) matches(v PolicyViolation) bool {
+	return globMatch(wv.PolicyID, v.PolicyID) && globMatch(wv.ResourceAddress, v.ResourceAddress)
+}
+
+// withStatus sets Status to "active" or "expired" as of now.
+func (wv Waiver) withStatus(now time.Time) Waiver {
+	wv.Status = "active"
+	if !now.Before(wv.ExpiresAt) {
+		wv.Status = "expired"
+	}
+	return wv
+}
+
+var waiverStore = newRecordStore(waiversFile, "Waiver", "waivers", func(wv Waiver) string { return wv.ID })
+
+func validateWaiver(wv *Waiver) error {
+	wv.PolicyID = strings.TrimSpace(wv.PolicyID)
+	wv.ResourceAddress = strings.TrimSpace(wv.ResourceAddress)
+	wv.Status = "" // derived on read
+	switch {
+	case wv.PolicyID == "":
+		return fmt.Errorf("policy_id is required")
+	case wv.ResourceAddress == "":
+		return fmt.Errorf("resource_address is required")
+	case strings.TrimSpace(wv.Reason) == "":
+		return fmt.Errorf("reason is required")
+	case strings.TrimSpace(wv.Owner) == "":
+		return fmt.Errorf("owner is required")
+	case wv.ExpiresAt.IsZero():
+		return fmt.Errorf("expires_at is required")
+	}
+	return nil
+}
+
+// applyWaivers moves violations and warnings covered by an active waiver to
+// policy_result.waived. A policy whose violations are all waived is dropped
+// from the pass/fail counts, and the compliance scores are adjusted to match.
+func applyWaivers(scan *ScanResult, now time.Time) error {
+	if scan.PolicyResult == nil {
+		return nil
+	}
+	waivers, err := waiverStore.load()
+	if err != nil {
+		return err
+	}
+	active := []Waiver{}
+	for _, wv := range waivers {
+		if now.Before(wv.ExpiresAt) {
+			active = append(active, wv)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	pr := scan.PolicyResult
+	split := func(findings []PolicyViolation) []PolicyViolation {
+		kept := []PolicyViolation{}
+		for _, v := range findings {
+			idx := slices.IndexFunc(active, func(wv Waiver) bool { return wv.matches(v) })
+			if idx < 0 {
+				kept = append(kept, v)
+				continue
+			}
+			v.Waived, v.WaiverID = true, active[idx].ID
+			pr.Waived = append(pr.Waived, v)
+		}
+		return kept
+	}
+
+	before := pr.Violations
+	pr.Violations = split(pr.Violations)
+	pr.Warnings = split(pr.Warnings)
+	rescorePolicies(pr, before)
+	return nil
+}
+
+// notifyExpiredWaivers sends waiver.expired once for each waiver that has
+// passed its expiry date.
+func notifyExpiredWaivers() {
+	waiverStore.mu.Lock()
+	defer waiverStore.mu.Unlock()
+	waivers, err := waiverStore.load()
+	if err != nil {
+		log.Printf("Failed to load waivers: %v", err)
+		return
+	}
+	now := time.Now().UTC()
+	changed := false
+	for i, wv := range waivers {
+		if wv.ExpiryNotified != nil || now.Before(wv.ExpiresAt) {
+			continue
+		}
+		wv = wv.withStatus(now)
+		dispatchEvent(eventWaiverExpired, wv)
+		notifyChannels(eventWaiverExpired, func(c NotificationChannel) chatMessage {
+			return waiverChatMessage(wv, c.uiURL())
+		})
+		waivers[i].ExpiryNotified = &now
+		changed = true
+	}
+	if changed {
+		if err := waiverStore.save(waivers); err != nil {
+			log.Printf("Failed to save waivers: %v", err)
+		}
+	}
+}
+
+// waiverChatMessage describes an expired waiver for chat channels.
+func waiverChatMessage(wv Waiver, uiURL string) chatMessage {
+	return chatMessage{
+		Title:   "Cloudrift waiver expired: " + wv.PolicyID,
+		Summary: fmt.Sprintf("The waiver for %s on %s expired on %s. Matching findings count against compliance again.", wv.PolicyID, wv.ResourceAddress, wv.ExpiresAt.Format("2006-01-02")),
+		Fields: []chatField{
+			{Label: "Policy", Value: wv.PolicyID},
+			{Label: "Resources", Value: wv.ResourceAddress},
+			{Label: "Owner", Value: orDash(wv.Owner)},
+			{Label: "Reason", Value: orDash(wv.Reason)},
+		},
+		Link:      uiURL + "/#/dashboard",
+		LinkLabel: "Open in Cloudrift",
+		Level:     "warning",
+	}
+}
+
+// GET/POST /api/waivers — List or create policy waivers.
+func handleWaivers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		waivers, err := waiverStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load waivers: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		status := r.URL.Query().Get("status")
+		out := []Waiver{}
+		for _, wv := range waivers {
+			wv = wv.withStatus(now)
+			if status == "" || wv.Status == status {
+				out = append(out, wv)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"waivers": out})
+	case http.MethodPost:
+		var wv Waiver
+		if err := json.NewDecoder(r.Body).Decode(&wv); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateWaiver(&wv); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !wv.ExpiresAt.After(time.Now()) {
+			jsonError(w, "expires_at must be in the future", http.StatusBadRequest)
+			return
+		}
+		wv.ID = fmt.Sprintf("wv-%d", time.Now().UnixNano())
+		wv.CreatedAt = time.Now().UTC()
+		wv.ExpiryNotified = nil
+		waiverStore.add(w, wv, wv.withStatus(time.Now()))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/waivers/{id} — Read, replace or remove a waiver.
+func handleWaiver(w http.ResponseWriter, r *http.Request) {
+	view := func(wv Waiver) interface{} { return wv.withStatus(time.Now()) }
+	waiverStore.serveRecord(w, r, view, func(old Waiver, updated *Waiver) (interface{}, error) {
+		updated.ID, updated.CreatedAt = old.ID, old.CreatedAt
+		if err := validateWaiver(updated); err != nil {
+			return nil, err
+		}
+		// Extending a waiver re-arms its expiry notification
+		updated.ExpiryNotified = old.ExpiryNotified
+		if updated.ExpiresAt.After(time.Now()) {
+			updated.ExpiryNotified = nil
+		}
+		return updated.withStatus(time.Now()), nil
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Drift ignore rules
+// ---------------------------------------------------------------------------
+
+const driftRulesFile = "drift_rules.json"
+
+// Drift fields an ignore rule can target.
+var driftRuleFields = []string{"diffs", "extra_attributes"}
+
+// DriftRule drops matching entries from the diffs and/or extra_attributes
+// of every drift. ResourceType and Attribute accept '*' and '?' wildcards;
+// an empty Field targets both maps.
+type DriftRule struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	ResourceType string    `json:"resource_type"`
+	Attribute    string    `json:"attribute"`
+	Field        string    `json:"field,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Enabled      bool      `json:"enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (rule DriftRule) matches(resourceType, field, attr string) bool {
+	return rule.Enabled && (rule.Field == "" || rule.Field == field) &&
+		globMatch(rule.ResourceType, resourceType) && globMatch(rule.Attribute, attr)
+}
+
+// IgnoredDrift is a drift entry dropped by an ignore rule.
+type IgnoredDrift struct {
+	ResourceType string      `json:"resource_type"`
+	ResourceID   string      `json:"resource_id"`
+	Field        string      `json:"field"`
+	Attribute    string      `json:"attribute"`
+	Value        interface{} `json:"value"`
+	RuleID       string      `json:"rule_id"`
+	RuleName     string      `json:"rule_name"`
+}
+
+var driftRuleStore = newRecordStore(driftRulesFile, "Drift rule", "drift rules", func(rule DriftRule) string { return rule.ID })
+
+func validateDriftRule(rule *DriftRule) error {
+	rule.ResourceType = strings.TrimSpace(rule.ResourceType)
+	rule.Attribute = strings.TrimSpace(rule.Attribute)
+	if rule.ResourceType == "" {
+		rule.ResourceType = "*"
+	}
+	if rule.Attribute == "" {
+		return fmt.Errorf("attribute is required")
+	}
+	if rule.Field != "" && !slices.Contains(driftRuleFields, rule.Field) {
+		return fmt.Errorf("field must be one of %s", strings.Join(driftRuleFields, ", "))
+	}
+	if rule.Name == "" {
+		rule.Name = rule.ResourceType + " " + rule.Attribute
+	}
+	return nil
+}
+
+// applyDriftRules removes entries matched by an enabled rule from each
+// drift and lists them under ignored_drifts with the rule that matched. A
+// resource left with no drift at all is dropped from drifts.
+func applyDriftRules(scan *ScanResult) error {
+	rules, err := driftRuleStore.load()
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	filter := func(d DriftInfo, field string, entries map[string]interface{}) map[string]interface{} {
+		kept := map[string]interface{}{}
+		for attr, v := range entries {
+			idx := slices.IndexFunc(rules, func(rule DriftRule) bool { return rule.matches(d.ResourceType, field, attr) })
+			if idx < 0 {
+				kept[attr] = v
+				continue
+			}
+			scan.Ignored = append(scan.Ignored, IgnoredDrift{
+				ResourceType: d.ResourceType, ResourceID: d.ResourceID, Field: field,
+				Attribute: attr, Value: v, RuleID: rules[idx].ID, RuleName: rules[idx].Name,
+			})
+		}
+		return kept
+	}
+
+	kept := []DriftInfo{}
+	for _, d := range scan.Drifts {
+		hadDrift := len(d.Diffs) > 0 || len(d.ExtraAttributes) > 0
+		d.Diffs = filter(d, "diffs", d.Diffs)
+		d.ExtraAttributes = filter(d, "extra_attributes", d.ExtraAttributes)
+		if hadDrift && !d.Missing && len(d.Diffs) == 0 && len(d.ExtraAttributes) == 0 {
+			if scan.DriftCount > 0 {
+				scan.DriftCount--
+			}
+			continue
+		}
+		kept = append(kept, d)
+	}
+	scan.Drifts = kept
+	sort.Slice(scan.Ignored, func(i, j int) bool {
+		a, b := scan.Ignored[i], scan.Ignored[j]
+		if a.ResourceID != b.ResourceID {
+			return a.ResourceID < b.ResourceID
+		}
+		return a.Attribute < b.Attribute
+	})
+	return nil
+}
+
+// GET/POST /api/drift-rules — List or create drift ignore rules.
+func handleDriftRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := driftRuleStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load drift rules: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"rules": rules, "fields": driftRuleFields})
+	case http.MethodPost:
+		rule := DriftRule{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateDriftRule(&rule); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule.ID = fmt.Sprintf("dr-%d", time.Now().UnixNano())
+		rule.CreatedAt = time.Now().UTC()
+		driftRuleStore.add(w, rule, rule)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/drift-rules/{id} — Read, replace or remove a rule.
+func handleDriftRule(w http.ResponseWriter, r *http.Request) {
+	view := func(rule DriftRule) interface{} { return rule }
+	driftRuleStore.serveRecord(w, r, view, func(old DriftRule, updated *DriftRule) (interface{}, error) {
+		updated.ID, updated.CreatedAt = old.ID, old.CreatedAt
+		if err := validateDriftRule(updated); err != nil {
+			return nil, err
+		}
+		return *updated, nil
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Drift baselines
+// ---------------------------------------------------------------------------
+
+const (
+	baselinesFile = "baselines.json"
+
+	// baselineMissing is the Baselines key recording that a resource's
+	// missing state was baselined.
+	baselineMissing = "missing"
+)
+
+// Baseline acknowledges the drift of a resource, or of one attribute, as
+// seen in a scan. Later scans only report drift that differs from it.
+type Baseline struct {
+	ID              string                 `json:"id"`
+	AccountID       string                 `json:"account_id"`
+	ResourceType    string                 `json:"resource_type"`
+	ResourceID      string                 `json:"resource_id"`
+	Attribute       string                 `json:"attribute,omitempty"`
+	Missing         bool                   `json:"missing,omitempty"`
+	Diffs           map[string]interface{} `json:"diffs,omitempty"`
+	ExtraAttributes map[string]interface{} `json:"extra_attributes,omitempty"`
+	Author          string                 `json:"author"`
+	Comment         string                 `json:"comment"`
+	ScanID          string                 `json:"scan_id"`
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+func (b Baseline) covers(accountID string, d DriftInfo) bool {
+	return b.ResourceType == d.ResourceType && b.ResourceID == d.ResourceID &&
+		(b.AccountID == "" || b.AccountID == accountID)
+}
+
+var baselineStore = newRecordStore(baselinesFile, "Baseline", "baselines", func(b Baseline) string { return b.ID })
+
+// applyBaselines moves drift that matches a baseline exactly from drifts to
+// baselined_drifts. A resource stays in drifts with whatever is left, and
+// drift_count only counts resources with new drift.
+func applyBaselines(scan *ScanResult) error {
+	baselines, err := baselineStore.load()
+	if err != nil || len(baselines) == 0 {
+		return err
+	}
+	kept := []DriftInfo{}
+	for _, d := range scan.Drifts {
+		current := d
+		current.Diffs = map[string]interface{}{}
+		current.ExtraAttributes = map[string]interface{}{}
+		baselined := d
+		baselined.Diffs = map[string]interface{}{}
+		baselined.ExtraAttributes = map[string]interface{}{}
+		baselined.Missing = false
+		baselined.Baselines = map[string]string{}
+
+		match := func(attr string, value interface{}, pick func(Baseline) map[string]interface{}) string {
+			for _, b := range baselines {
+				if !b.covers(scan.AccountID, d) || (b.Attribute != "" && b.Attribute != attr) {
+					continue
+				}
+				if v, ok := pick(b)[attr]; ok && reflect.DeepEqual(v, value) {
+					return b.ID
+				}
+			}
+			return ""
+		}
+		for attr, v := range d.Diffs {
+			if id := match(attr, v, func(b Baseline) map[string]interface{} { return b.Diffs }); id != "" {
+				baselined.Diffs[attr] = v
+				baselined.Baselines[attr] = id
+			} else {
+				current.Diffs[attr] = v
+			}
+		}
+		for attr, v := range d.ExtraAttributes {
+			if id := match(attr, v, func(b Baseline) map[string]interface{} { return b.ExtraAttributes }); id != "" {
+				baselined.ExtraAttributes[attr] = v
+				baselined.Baselines[attr] = id
+			} else {
+				current.ExtraAttributes[attr] = v
+			}
+		}
+		if d.Missing {
+			idx := slices.IndexFunc(baselines, func(b Baseline) bool {
+				return b.covers(scan.AccountID, d) && b.Attribute == "" && b.Missing
+			})
+			if idx >= 0 {
+				current.Missing = false
+				baselined.Missing = true
+				baselined.Baselines[baselineMissing] = baselines[idx].ID
+			}
+		}
+
+		if len(baselined.Baselines) == 0 {
+			kept = append(kept, d)
+			continue
+		}
+		scan.Baselined = append(scan.Baselined, baselined)
+		if current.Missing || len(current.Diffs) > 0 || len(current.ExtraAttributes) > 0 {
+			kept = append(kept, current)
+		} else if scan.DriftCount > 0 {
+			scan.DriftCount--
+		}
+	}
+	scan.Drifts = kept
+	return nil
+}
+
+// GET/POST /api/baselines — List baselines or acknowledge the current drift
+// of a resource or attribute.
+func handleBaselines(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		baselines, err := baselineStore.load()
+		if err != nil {
+			jsonError(w, "Failed to load baselines: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		q := r.URL.Query()
+		out := []Baseline{}
+		for _, b := range baselines {
+			if (q.Get("resource_type") == "" || b.ResourceType == q.Get("resource_type")) &&
+				(q.Get("resource_id") == "" || b.ResourceID == q.Get("resource_id")) {
+				out = append(out, b)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"baselines": out})
+	case http.MethodPost:
+		var req struct {
+			ScanID       string `json:"scan_id"`
+			ResourceType string `json:"resource_type"`
+			ResourceID   string `json:"resource_id"`
+			Attribute    string `json:"attribute"`
+			Author       string `json:"author"`
+			Comment      string `json:"comment"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ResourceType == "" || req.ResourceID == "" {
+			jsonError(w, "resource_type and resource_id are required", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Author) == "" || strings.TrimSpace(req.Comment) == "" {
+			jsonError(w, "author and comment are required", http.StatusBadRequest)
+			return
+		}
+		rec, err := latestOrNamedScan(req.ScanID)
+		if err != nil {
+			jsonError(w, "Scan not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		scan, err := rec.Scan()
+		if err != nil {
+			jsonError(w, "Failed to read scan: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Baseline what the scan reported, including drift that was already
+		// baselined, so re-acknowledging a resource does not lose anything.
+		b := Baseline{
+			AccountID: scan.AccountID, ResourceType: req.ResourceType, ResourceID: req.ResourceID,
+			Attribute: req.Attribute, Diffs: map[string]interface{}{}, ExtraAttributes: map[string]interface{}{},
+			Author: req.Author, Comment: req.Comment, ScanID: rec.ID, CreatedAt: time.Now().UTC(),
+		}
+		found := false
+		for _, d := range append(scan.Drifts, scan.Baselined...) {
+			if d.ResourceType != req.ResourceType || d.ResourceID != req.ResourceID {
+				continue
+			}
+			found = true
+			for k, v := range d.Diffs {
+				if req.Attribute == "" || req.Attribute == k {
+					b.Diffs[k] = v
+				}
+			}
+			for k, v := range d.ExtraAttributes {
+				if req.Attribute == "" || req.Attribute == k {
+					b.ExtraAttributes[k] = v
+				}
+			}
+			b.Missing = b.Missing || (d.Missing && req.Attribute == "")
+		}
+		if !found {
+			jsonError(w, fmt.Sprintf("No drift for %s %s in scan %s", req.ResourceType, req.ResourceID, rec.ID), http.StatusNotFound)
+			return
+		}
+		if req.Attribute != "" && len(b.Diffs) == 0 && len(b.ExtraAttributes) == 0 {
+			jsonError(w, fmt.Sprintf("No drift on attribute %s of %s %s in scan %s", req.Attribute, req.ResourceType, req.ResourceID, rec.ID), http.StatusNotFound)
+			return
+		}
+		b.ID = fmt.Sprintf("bl-%d", time.Now().UnixNano())
+		baselineStore.update(w, func(baselines *[]Baseline) (interface{}, int, bool) {
+			// A new acknowledgement replaces the previous one for the same target
+			*baselines = slices.DeleteFunc(*baselines, func(o Baseline) bool {
+				return o.AccountID == b.AccountID && o.ResourceType == b.ResourceType &&
+					o.ResourceID == b.ResourceID && o.Attribute == b.Attribute
+			})
+			*baselines = append(*baselines, b)
+			return b, http.StatusCreated, true
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/DELETE /api/baselines/{id} — Read or remove a baseline.
+func handleBaseline(w http.ResponseWriter, r *http.Request) {
+	baselineStore.serveRecord(w, r, func(b Baseline) interface{} { return b }, nil)
+}
+
+// ---------------------------------------------------------------------------
+// Drift remediation
+// ---------------------------------------------------------------------------
+
+// Remediation directions: "code" changes Terraform to match the cloud,
+// "cloud" re-applies Terraform so the cloud matches the code again.
+var remediationDirections = []string{"code", "cloud"}
+
+// computedAttributes are read-only attributes that show up in
+// extra_attributes but cannot be set in configuration.
+var computedAttributes = map[string]bool{
+	"id": true, "arn": true, "owner_id": true, "unique_id": true,
+	"creation_date": true, "create_date": true, "tags_all": true,
+	"bucket_domain_name": true, "bucket_regional_domain_name": true,
+	"hosted_zone_id": true,
+}
+
+// mapAttributes are top-level attributes whose nested keys are map entries
+// rather than nested blocks.
+var mapAttributes = map[string]bool{"tags": true, "labels": true, "environment": true}
+
+// Remediation is a suggested fix for the drift of one resource address.
+// Kind is "update" for diffs, "adopt" for extra_attributes, and "import" or
+// "apply" for a missing resource. HCL is the configuration to add or change,
+// Patch shows the change as a diff of the resource block and Command is the
+// Terraform command to run afterwards.
+type Remediation struct {
+	Address      string   `json:"address"`
+	ResourceType string   `json:"resource_type"`
+	ResourceID   string   `json:"resource_id"`
+	Kind         string   `json:"kind"`
+	Attributes   []string `json:"attributes,omitempty"`
+	Skipped      []string `json:"skipped,omitempty"`
+	HCL          string   `json:"hcl,omitempty"`
+	Patch        string   `json:"patch,omitempty"`
+	Command      string   `json:"command,omitempty"`
+}
+
+// hclChange is one attribute of a remediation. Old or new is absent when
+// the attribute is added or removed.
+type hclChange struct {
+	path           []string
+	old, new       interface{}
+	hasOld, hasNew bool
+}
+
+// remediateDrift suggests remediations for one drifted resource.
+func remediateDrift(d DriftInfo, direction string) []Remediation {
+	address := d.Address()
+	base := Remediation{Address: address, ResourceType: d.ResourceType, ResourceID: d.ResourceID}
+	if d.Missing {
+		rem := base
+		if direction == "cloud" {
+			rem.Kind = "apply"
+			rem.Command = "terraform apply -target=" + address
+			return []Remediation{rem}
+		}
+		rem.Kind = "import"
+		rem.HCL = fmt.Sprintf("import {\n  to = %s\n  id = %s\n}\n", address, hclValue(d.ResourceID, 1))
+		rem.Patch = strings.Join(prefixLines(rem.HCL, "+"), "")
+		rem.Command = "terraform plan -generate-config-out=generated.tf"
+		return []Remediation{rem}
+	}
+
+	var rems []Remediation
+	if len(d.Diffs) > 0 {
+		rem := base
+		rem.Kind = "update"
+		var changes []hclChange
+		for _, attr := range slices.Sorted(maps.Keys(d.Diffs)) {
+			// A null side means the attribute is not set there
+			expected, actual := diffPair(d.Diffs[attr])
+			c := hclChange{path: hclPath(attr), old: expected, hasOld: expected != nil, new: actual, hasNew: actual != nil}
+			if direction == "cloud" {
+				c = hclChange{path: c.path, old: actual, hasOld: actual != nil, new: expected, hasNew: expected != nil}
+			}
+			changes = append(changes, c)
+			rem.Attributes = append(rem.Attributes, attr)
+		}
+		rem.Patch = renderHCLPatch(d.ResourceType, d.Name(), changes)
+		if direction == "cloud" {
+			rem.Command = "terraform apply -target=" + address
+		} else {
+			rem.HCL = renderHCLBlock(d.ResourceType, d.Name(), changes)
+		}
+		rems = append(rems, rem)
+	}
+	if len(d.ExtraAttributes) > 0 && direction == "code" {
+		rem := base
+		rem.Kind = "adopt"
+		var changes []hclChange
+		for _, attr := range slices.Sorted(maps.Keys(d.ExtraAttributes)) {
+			path := hclPath(attr)
+			if computedAttributes[path[0]] {
+				rem.Skipped = append(rem.Skipped, attr)
+				continue
+			}
+			changes = append(changes, hclChange{path: path, new: d.ExtraAttributes[attr], hasNew: true})
+			rem.Attributes = append(rem.Attributes, attr)
+		}
+		if len(changes) > 0 {
+			rem.HCL = renderHCLBlock(d.ResourceType, d.Name(), changes)
+			rem.Patch = renderHCLPatch(d.ResourceType, d.Name(), changes)
+		}
+		rems = append(rems, rem)
+	}
+	return rems
+}
+
+// hclPath splits a dotted drift attribute into HCL path segments. List
+// indexes are dropped so "rule.0.status" becomes the nested block
+// rule { status }.
+func hclPath(attr string) []string {
+	var path []string
+	for _, seg := range strings.Split(attr, ".") {
+		if _, err := strconv.Atoi(seg); err == nil && len(path) > 0 {
+			continue
+		}
+		path = append(path, seg)
+	}
+	return path
+}
+
+// renderHCLBlock renders the new values of changes as a resource block.
+func renderHCLBlock(resourceType, name string, changes []hclChange) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("resource %q %q {\n", resourceType, name))
+	renderHCLChanges(&lines, changes, 1, false, false)
+	lines = append(lines, "}\n")
+	return strings.Join(lines, "")
+}
+
+// renderHCLPatch renders changes as a diff of the resource block: removed
+// values are prefixed with "-", added values with "+" and context with a
+// space.
+func renderHCLPatch(resourceType, name string, changes []hclChange) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf(" resource %q %q {\n", resourceType, name))
+	renderHCLChanges(&lines, changes, 1, false, true)
+	lines = append(lines, " }\n")
+	return strings.Join(lines, "")
+}
+
+// renderHCLChanges appends the lines of changes, grouped by their first
+// path segment, at the given nesting depth.
+func renderHCLChanges(lines *[]string, changes []hclChange, depth int, inMap, patch bool) {
+	indent := strings.Repeat("  ", depth)
+	context := func(s string) {
+		if patch {
+			s = " " + s
+		}
+		*lines = append(*lines, s+"\n")
+	}
+
+	var groups []string
+	byName := map[string][]hclChange{}
+	for _, c := range changes {
+		if _, ok := byName[c.path[0]]; !ok {
+			groups = append(groups, c.path[0])
+		}
+		byName[c.path[0]] = append(byName[c.path[0]], c)
+	}
+	sort.Strings(groups)
+
+	for _, name := range groups {
+		group := byName[name]
+		key := name
+		if inMap {
+			key = hclKey(name)
+		}
+		if len(group) == 1 && len(group[0].path) == 1 {
+			c := group[0]
+			if !patch {
+				if c.hasNew {
+					*lines = append(*lines, fmt.Sprintf("%s%s = %s\n", indent, key, hclValue(c.new, depth)))
+				}
+				continue
+			}
+			if c.hasOld {
+				*lines = append(*lines, fmt.Sprintf("-%s%s = %s\n", indent, key, hclValue(c.old, depth)))
+			}
+			if c.hasNew {
+				*lines = append(*lines, fmt.Sprintf("+%s%s = %s\n", indent, key, hclValue(c.new, depth)))
+			}
+			continue
+		}
+
+		var nested []hclChange
+		for _, c := range group {
+			if len(c.path) > 1 {
+				c.path = c.path[1:]
+				nested = append(nested, c)
+			}
+		}
+		isMap := inMap || (depth == 1 && mapAttributes[name])
+		if isMap {
+			context(fmt.Sprintf("%s%s = {", indent, key))
+		} else {
+			context(fmt.Sprintf("%s%s {", indent, key))
+		}
+		renderHCLChanges(lines, nested, depth+1, isMap, patch)
+		context(indent + "}")
+	}
+}
+
+// hclIdentifier matches keys that need no quoting in HCL.
+var hclIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// hclKey renders a map key, quoting it when it is not an identifier.
+func hclKey(k string) string {
+	if hclIdentifier.MatchString(k) {
+		return k
+	}
+	return hclString(k)
+}
+
+// hclString quotes s as an HCL string literal, escaping template sequences.
+func hclString(s string) string {
+	q := strconv.Quote(s)
+	q = strings.ReplaceAll(q, "${", "$${")
+	return strings.ReplaceAll(q, "%{", "%%{")
+}
+
+// hclValue renders a JSON-decoded value as an HCL expression. Maps and
+// lists are rendered over several lines, indented for the given depth.
+func hclValue(v interface{}, depth int) string {
+	indent := strings.Repeat("  ", depth)
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return hclString(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case []interface{}:
+		if len(val) == 0 {
+			return "[]"
+		}
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = indent + "  " + hclValue(item, depth+1) + ",\n"
+		}
+		return "[\n" + strings.Join(items, "") + indent + "]"
+	case map[string]interface{}:
+		if len(val) == 0 {
+			return "{}"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range slices.Sorted(maps.Keys(val)) {
+			fmt.Fprintf(&b, "%s  %s = %s\n", indent, hclKey(k), hclValue(val[k], depth+1))
+		}
+		return b.String() + indent + "}"
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+// prefixLines splits s into lines, each prefixed with p.
+func prefixLines(s, p string) []string {
+	var lines []string
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n") {
+		lines = append(lines, p+strings.TrimSuffix(line, "\n")+"\n")
+	}
+	return lines
+}
+
+// GET /api/remediation?scan=<id>&resource=<address|id>&direction=code|cloud —
+// Suggested Terraform changes for the drift of a scan (the latest by
+// default), optionally for one resource.
+func handleRemediation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	direction := q.Get("direction")
+	if direction == "" {
+		direction = "code"
+	}
+	if !slices.Contains(remediationDirections, direction) {
+		jsonError(w, "direction must be code or cloud", http.StatusBadRequest)
+		return
+	}
+	rec, err := latestOrNamedScan(q.Get("scan"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	scan, err := rec.Scan()
+	if err != nil {
+		jsonError(w, "Failed to read scan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resource := q.Get("resource")
+	remediations := []Remediation{}
+	found := false
+	for _, d := range scan.Drifts {
+		if resource != "" && resource != d.Address() && resource != d.ResourceID {
+			continue
+		}
+		found = true
+		remediations = append(remediations, remediateDrift(d, direction)...)
+	}
+	if resource != "" && !found {
+		jsonError(w, "No drift for resource: "+resource, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scan_id":      rec.ID,
+		"direction":    direction,
+		"remediations": remediations,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Issue tracker integration
+// ---------------------------------------------------------------------------
+
+// Issue is a ticket to open for a policy violation on one resource.
+type Issue struct {
+	Key             string   `json:"key"`
+	Title           string   `json:"title"`
+	Description     string   `json:"description"`
+	Labels          []string `json:"labels"`
+	PolicyID        string   `json:"policy_id"`
+	ResourceAddress string   `json:"resource_address"`
+	Severity        string   `json:"severity"`
+	Service         string   `json:"service"`
+	AccountID       string   `json:"account_id"`
+	Region          string   `json:"region"`
+}
+
+// IssueRef identifies an issue in the external tracker.
+type IssueRef struct {
+	ID  string `json:"id"`
+	URL string `json:"url,omitempty"`
+}
+
+// IssueTracker is implemented by each supported issue tracker.
+type IssueTracker interface {
+	CreateIssue(ctx context.Context, issue Issue) (IssueRef, error)
+	CloseIssue(ctx context.Context, ref IssueRef, comment string) error
+}
+
+// issueTrackers maps a tracker type to its constructor.
+var issueTrackers = map[string]func(cfg IssueTrackerConfig) (IssueTracker, error){
+	"rest": newRESTTracker,
+}
+
+const (
+	issueTrackerFile = "issue_tracker.json"
+	ticketsFile      = "tickets.json"
+)
+
+// IssueTrackerConfig selects and configures the issue tracker. Fields other
+// than Type, Enabled and MinSeverity are used by the generic REST adapter.
+type IssueTrackerConfig struct {
+	Type        string            `json:"type"`
+	Enabled     bool              `json:"enabled"`
+	MinSeverity string            `json:"min_severity"`
+	BaseURL     string            `json:"base_url"`
+	CreatePath  string            `json:"create_path"`
+	ClosePath   string            `json:"close_path"`
+	CloseMethod string            `json:"close_method"`
+	Headers     map[string]string `json:"headers"`
+	IDField     string            `json:"id_field"`
+	URLField    string            `json:"url_field"`
+}
+
+// redacted masks header values, which usually hold API tokens.
+func (c IssueTrackerConfig) redacted() IssueTrackerConfig {
+	headers := map[string]string{}
+	for k := range c.Headers {
+		headers[k] = "********"
+	}
+	c.Headers = headers
+	return c
+}
+
+// Ticket tracks the issue opened for one policy × resource.
+type Ticket struct {
+	Key             string     `json:"key"`
+	PolicyID        string     `json:"policy_id"`
+	ResourceAddress string     `json:"resource_address"`
+	Service         string     `json:"service"`
+	AccountID       string     `json:"account_id"`
+	Severity        string     `json:"severity"`
+	Status          string     `json:"status"`
+	Ref             IssueRef   `json:"ref"`
+	OpenedAt        time.Time  `json:"opened_at"`
+	OpenedByScan    string     `json:"opened_by_scan"`
+	LastSeenScan    string     `json:"last_seen_scan"`
+	ClosedAt        *time.Time `json:"closed_at,omitempty"`
+	ClosedByScan    string     `json:"closed_by_scan,omitempty"`
+}
+
+// ticketsMu guards the tickets file. ticketSyncMu runs one sync at a time,
+// so two scans cannot open the same issue, without holding ticketsMu while
+// the tracker is called.
+var (
+	ticketsMu    sync.Mutex
+	ticketSyncMu sync.Mutex
+)
+
+func loadIssueTrackerConfig() (IssueTrackerConfig, error) {
+	cfg := IssueTrackerConfig{Type: "rest", MinSeverity: "critical", Headers: map[string]string{}}
+	err := loadDataFile(issueTrackerFile, &cfg)
+	return cfg, err
+}
+
+func loadTickets() ([]Ticket, error) {
+	tickets := []Ticket{}
+	err := loadDataFile(ticketsFile, &tickets)
+	return tickets, err
+}
+
+// ticketKey identifies a violation across scans.
+func ticketKey(accountID, policyID, resourceAddress string) string {
+	return accountID + "/" + policyID + "/" + resourceAddress
+}
+
+// restTracker is a generic adapter for JSON REST issue trackers. Issues are
+// created with POST {base_url}{create_path}; the new ID and URL are read from
+// the response fields id_field and url_field. Issues are closed by sending
+// close_method to {base_url}{close_path}, where {id} is replaced.
+type restTracker struct {
+	cfg    IssueTrackerConfig
+	client *http.Client
+}
+
+func newRESTTracker(cfg IssueTrackerConfig) (IssueTracker, error) {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("base_url must be an absolute http(s) URL")
+	}
+	if cfg.CreatePath == "" {
+		cfg.CreatePath = "/issues"
+	}
+	if cfg.ClosePath == "" {
+		cfg.ClosePath = "/issues/{id}"
+	}
+	if cfg.CloseMethod == "" {
+		cfg.CloseMethod = http.MethodPatch
+	}
+	if cfg.IDField == "" {
+		cfg.IDField = "id"
+	}
+	if cfg.URLField == "" {
+		cfg.URLField = "url"
+	}
+	return &restTracker{cfg: cfg, client: &http.Client{Timeout: 15 * time.Second}}, nil
+}
+
+func (t *restTracker) do(ctx context.Context, method, path string, payload interface{}) (map[string]interface{}, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(t.cfg.BaseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	result := map[string]interface{}{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		json.Unmarshal(data, &result)
+	}
+	return result, nil
+}
+
+func (t *restTracker) CreateIssue(ctx context.Context, issue Issue) (IssueRef, error) {
+	result, err := t.do(ctx, http.MethodPost, t.cfg.CreatePath, issue)
+	if err != nil {
+		return IssueRef{}, err
+	}
+	ref := IssueRef{ID: csvValue(result[t.cfg.IDField])}
+	if ref.ID == "" {
+		return IssueRef{}, fmt.Errorf("response has no %q field", t.cfg.IDField)
+	}
+	ref.URL, _ = result[t.cfg.URLField].(string)
+	return ref, nil
+}
+
+func (t *restTracker) CloseIssue(ctx context.Context, ref IssueRef, comment string) error {
+	path := strings.ReplaceAll(t.cfg.ClosePath, "{id}", url.PathEscape(ref.ID))
+	_, err := t.do(ctx, t.cfg.CloseMethod, path, map[string]string{"state": "closed", "comment": comment})
+	return err
+}
+
+func newIssueTracker(cfg IssueTrackerConfig) (IssueTracker, error) {
+	factory, ok := issueTrackers[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown issue tracker type: %s", cfg.Type)
+	}
+	return factory(cfg)
+}
+
+// ticketSyncResult reports what a sync changed.
+type ticketSyncResult struct {
+	Opened       []Ticket `json:"opened"`
+	Closed       []Ticket `json:"closed"`
+	Skipped      int      `json:"already_open"`
+	CloseSkipped string   `json:"close_skipped,omitempty"`
+	Errors       []string `json:"errors"`
+}
+
+// latestScanFor reports whether rec is the newest stored scan of its service
+// and account. Scan IDs carry their creation time, so only scans with a later
+// ID are loaded.
+func latestScanFor(rec *StoredScan, accountID string) (bool, error) {
+	scanStoreMu.Lock()
+	entries, err := os.ReadDir(filepath.Join(dataDir(), "scans"))
+	scanStoreMu.Unlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	recTime, recTimed := scanIDTime(rec.ID)
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || id == rec.ID {
+			continue
+		}
+		if t, ok := scanIDTime(id); ok && recTimed && t <= recTime {
+			continue
+		}
+		s, err := loadScan(id)
+		if err != nil || s.Service != rec.Service || !s.CreatedAt.After(rec.CreatedAt) {
+			continue
+		}
+		if scan, err := s.Scan(); err == nil && scan.AccountID == accountID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// scanIDTime returns the creation time, in Unix nanoseconds, encoded in a
+// scan ID.
+func scanIDTime(id string) (int64, bool) {
+	rest, ok := strings.CutPrefix(id, "scan-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(rest, 10, 64)
+	return n, err == nil
+}
+
+// syncTickets opens one issue per new qualifying violation × resource and
+// closes issues whose violation no longer appears. Only tickets for the
+// scan's service and account are considered for closing, and only when the
+// scan evaluated policies and is the newest for that scope: a scan without
+// policy results, or an older one synced by hand, says nothing about whether
+// a violation is gone.
+func syncTickets(rec *StoredScan) (*ticketSyncResult, error) {
+	result := &ticketSyncResult{Opened: []Ticket{}, Closed: []Ticket{}, Errors: []string{}}
+	cfg, err := loadIssueTrackerConfig()
+	if err != nil || !cfg.Enabled {
+		return result, err
+	}
+	tracker, err := newIssueTracker(cfg)
+	if err != nil {
+		log.Printf("Issue tracker misconfigured: %v", err)
+		return result, err
+	}
+	scan, err := rec.Scan()
+	if err != nil {
+		return result, err
+	}
+
+	ticketSyncMu.Lock()
+	defer ticketSyncMu.Unlock()
+
+	// Decide what to open and close from the stored tickets, then call the
+	// tracker without holding ticketsMu.
+	ticketsMu.Lock()
+	tickets, err := loadTickets()
+	ticketsMu.Unlock()
+	if err != nil {
+		return result, err
+	}
+	open := map[string]bool{}
+	for _, t := range tickets {
+		if t.Status == "open" {
+			open[t.Key] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	var toOpen []PolicyViolation
+	if scan.PolicyResult != nil {
+		for _, v := range scan.PolicyResult.Violations {
+			if severityRank(v.Severity) > severityRank(cfg.MinSeverity) {
+				continue
+			}
+			key := ticketKey(scan.AccountID, v.PolicyID, v.ResourceAddress)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if open[key] {
+				result.Skipped++
+				continue
+			}
+			toOpen = append(toOpen, v)
+		}
+	}
+
+	switch latest, err := latestScanFor(rec, scan.AccountID); {
+	case scan.PolicyResult == nil:
+		result.CloseSkipped = "scan has no policy results"
+	case err != nil:
+		result.CloseSkipped = "failed to list scans: " + err.Error()
+	case !latest:
+		result.CloseSkipped = "a newer scan exists for this service and account"
+	}
+	var toClose []Ticket
+	if result.CloseSkipped == "" {
+		for _, t := range tickets {
+			if t.Status == "open" && !seen[t.Key] && t.Service == rec.Service && t.AccountID == scan.AccountID {
+				toClose = append(toClose, t)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	for _, v := range toOpen {
+		key := ticketKey(scan.AccountID, v.PolicyID, v.ResourceAddress)
+		ref, err := tracker.CreateIssue(ctx, Issue{
+			Key:   key,
+			Title: fmt.Sprintf("[%s] %s on %s", v.PolicyID, v.PolicyName, v.ResourceAddress),
+			Description: fmt.Sprintf("%s\n\nSeverity: %s\nResource: %s\nAccount: %s (%s)\nFrameworks: %s\n\nRemediation: %s\n\nDetected by Cloudrift scan %s.",
+				v.Message, v.Severity, v.ResourceAddress, scan.AccountID, scan.Region,
+				strings.Join(v.Frameworks, ", "), v.Remediation, rec.ID),
+			Labels:          append([]string{"cloudrift", strings.ToLower(v.Severity), v.PolicyID}, v.Frameworks...),
+			PolicyID:        v.PolicyID,
+			ResourceAddress: v.ResourceAddress,
+			Severity:        v.Severity,
+			Service:         rec.Service,
+			AccountID:       scan.AccountID,
+			Region:          scan.Region,
+		})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("create %s: %v", key, err))
+			continue
+		}
+		result.Opened = append(result.Opened, Ticket{
+			Key: key, PolicyID: v.PolicyID, ResourceAddress: v.ResourceAddress,
+			Service: rec.Service, AccountID: scan.AccountID, Severity: v.Severity,
+			Status: "open", Ref: ref, OpenedAt: time.Now().UTC(),
+			OpenedByScan: rec.ID, LastSeenScan: rec.ID,
+		})
+	}
+	closed := map[string]time.Time{}
+	for _, t := range toClose {
+		comment := fmt.Sprintf("Resolved: %s no longer reported on %s by Cloudrift scan %s.", t.PolicyID, t.ResourceAddress, rec.ID)
+		if err := tracker.CloseIssue(ctx, t.Ref, comment); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("close %s: %v", t.Key, err))
+			continue
+		}
+		closed[t.Key] = time.Now().UTC()
+	}
+
+	for _, e := range result.Errors {
+		log.Printf("Ticket sync for %s: %s", rec.ID, e)
+	}
+
+	ticketsMu.Lock()
+	defer ticketsMu.Unlock()
+	tickets, err = loadTickets()
+	if err != nil {
+		return result, err
+	}
+	for i, t := range tickets {
+		if t.Status != "open" {
+			continue
+		}
+		if seen[t.Key] {
+			tickets[i].LastSeenScan = rec.ID
+		}
+		if at, ok := closed[t.Key]; ok {
+			tickets[i].Status, tickets[i].ClosedAt, tickets[i].ClosedByScan = "closed", &at, rec.ID
+			result.Closed = append(result.Closed, tickets[i])
+		}
+	}
+	tickets = append(tickets, result.Opened...)
+	return result, saveDataFile(ticketsFile, tickets)
+}
+
+// GET /api/tickets?status=open|closed — List tracked tickets.
+func handleTickets(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	ticketsMu.Lock()
+	tickets, err := loadTickets()
+	ticketsMu.Unlock()
+	if err != nil {
+		jsonError(w, "Failed to load tickets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	out := []Ticket{}
+	for _, t := range tickets {
+		if status == "" || t.Status == status {
+			out = append(out, t)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tickets": out})
+}
+
+// GET/PUT /api/tickets/config — Read or replace the issue tracker config.
+func handleTicketConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := loadIssueTrackerConfig()
+		if err != nil {
+			jsonError(w, "Failed to load issue tracker config: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		types := make([]string, 0, len(issueTrackers))
+		for t := range issueTrackers {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"config": cfg.redacted(), "types": types})
+	case http.MethodPut:
+		current, err := loadIssueTrackerConfig()
+		if err != nil {
+			jsonError(w, "Failed to load issue tracker config: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cfg := IssueTrackerConfig{Type: "rest", MinSeverity: "critical"}
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Masked header values keep their stored value
+		for k, v := range cfg.Headers {
+			if v == "********" {
+				cfg.Headers[k] = current.Headers[k]
+			}
+		}
+		if severityRank(cfg.MinSeverity) == len(severityOrder) {
+			jsonError(w, "min_severity must be one of "+strings.Join(severityOrder, ", "), http.StatusBadRequest)
+			return
+		}
+		if _, err := newIssueTracker(cfg); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := saveDataFile(issueTrackerFile, cfg); err != nil {
+			jsonError(w, "Failed to save issue tracker config: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"config": cfg.redacted()})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// POST /api/tickets/sync?scan=<id> — Sync tickets against a stored scan
+// (the latest by default) and report what changed.
+func handleTicketSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rec, err := latestOrNamedScan(r.URL.Query().Get("scan"))
+	if err != nil {
+		jsonError(w, "Scan not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	cfg, err := loadIssueTrackerConfig()
+	if err != nil {
+		jsonError(w, "Failed to load issue tracker config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !cfg.Enabled {
+		jsonError(w, "Issue tracker is not enabled", http.StatusBadRequest)
+		return
+	}
+	result, err := syncTickets(rec)
+	if err != nil {
+		jsonError(w, "Ticket sync failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
 
-	cmd := exec.Command(terraformPath(), "version", "-json")
-	versionOutput, versionErr := cmd.Output()
-	available := versionErr == nil
+// ---------------------------------------------------------------------------
+// Policy catalog
+// ---------------------------------------------------------------------------
+
+// PolicyCatalogEntry describes one policy the scanner can report.
+type PolicyCatalogEntry struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Category    string   `json:"category"`
+	Severity    string   `json:"default_severity"`
+	Service     string   `json:"service"`
+	Description string   `json:"description"`
+	Remediation string   `json:"remediation"`
+	Frameworks  []string `json:"frameworks"`
+	Source      string   `json:"source"` // "cli" or "custom"
+	Team        string   `json:"team,omitempty"`
+	File        string   `json:"file,omitempty"`
+}
+
+// cliCommands lists the subcommands the installed CLI advertises under
+// "Available Commands:" in its --help output.
+// The list is cached until the CLI binary changes.
+func cliCommands() ([]string, error) {
+	v, err := cachedToolValue(cliPath(), "commands", func() (interface{}, error) { return listCLICommands() })
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+func listCLICommands() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, cliPath(), "--help").Output()
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	var commands []string
+	inList := false
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.TrimSpace(line) == "Available Commands:":
+			inList = true
+		case inList && strings.TrimSpace(line) == "":
+			inList = false
+		case inList:
+			if fields := strings.Fields(line); len(fields) > 0 {
+				commands = append(commands, fields[0])
+			}
+		}
+	}
+	return commands, nil
+}
+
+// cliPolicies asks the CLI for its embedded policies with
+// `cloudrift policies --format=json`. The command is only run when the CLI
+// lists it in its help; older CLIs have no way to list their policies.
+func cliPolicies() ([]PolicyCatalogEntry, error) {
+	commands, err := cliCommands()
+	if err != nil {
+		return nil, fmt.Errorf("cloudrift CLI not available: %w", err)
+	}
+	if !slices.Contains(commands, "policies") {
+		return nil, fmt.Errorf("the installed cloudrift CLI has no policies command to list its embedded policies")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, cliPath(), "policies", "--format=json")
+	if wd := workDir(); wd != "" {
+		cmd.Dir = wd
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cloudrift policies failed: %w", err)
+	}
+	return parseCLIPolicies(out)
+}
 
-	var version string
-	if available {
-		var vInfo map[string]interface{}
-		if json.Unmarshal(versionOutput, &vInfo) == nil {
-			version, _ = vInfo["terraform_version"].(string)
+// parseCLIPolicies reads the output of `cloudrift policies --format=json`,
+// either a bare array or {"policies": [...]}. Unlike scan output it is
+// parsed whole, as extractJSON only finds objects.
+func parseCLIPolicies(out []byte) ([]PolicyCatalogEntry, error) {
+	out = bytes.TrimSpace(out)
+	var entries []PolicyCatalogEntry
+	if err := json.Unmarshal(out, &entries); err != nil {
+		var wrapped struct {
+			Policies []PolicyCatalogEntry `json:"policies"`
+		}
+		if err := json.Unmarshal(out, &wrapped); err != nil {
+			return nil, fmt.Errorf("cloudrift policies returned invalid JSON: %w", err)
 		}
+		entries = wrapped.Policies
 	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("cloudrift policies returned no policies")
+	}
+	for i := range entries {
+		entries[i].Source = "cli"
+		if entries[i].Frameworks == nil {
+			entries[i].Frameworks = []string{}
+		}
+	}
+	return entries, nil
+}
 
-	tfDir := filepath.Join(workDir(), "terraform")
-	tfFiles := []string{}
-	if entries, err := os.ReadDir(tfDir); err == nil {
-		for _, e := range entries {
-			if !e.IsDir() && strings.HasSuffix(e.Name(), ".tf") {
-				tfFiles = append(tfFiles, e.Name())
+// customPolicyEntries reads catalog entries from the METADATA annotations
+// of managed policies. A rule annotated like
+//
+//	# METADATA
+//	# title: S3 Team Tag Required
+//	# description: Buckets must have a Team tag.
+//	# custom:
+//	#   id: CUSTOM-S3-001
+//	#   severity: high
+//	#   category: tagging
+//	#   service: S3
+//	#   remediation: Add a Team tag
+//	#   frameworks: [soc2]
+//
+// becomes one entry. A file without annotations is listed once under its
+// package name.
+func customPolicyEntries() ([]PolicyCatalogEntry, error) {
+	files, err := listPolicyFiles("")
+	if err != nil {
+		return nil, err
+	}
+	entries := []PolicyCatalogEntry{}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "_test.rego") {
+			continue
+		}
+		full, err := safePath(f.Path)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			continue
+		}
+		for _, e := range regoPolicyEntries(string(data), f.Name) {
+			e.Source, e.Team, e.File = "custom", f.Team, f.Path
+			if e.Category == "" {
+				e.Category = "custom"
+			}
+			if e.Severity == "" {
+				e.Severity = "medium"
 			}
+			if e.Service == "" {
+				e.Service = "All"
+			}
+			entries = append(entries, e)
 		}
 	}
+	return entries, nil
+}
 
-	_, initErr := os.Stat(filepath.Join(tfDir, ".terraform"))
-	initialized := initErr == nil
+// regoPolicyEntries returns the catalog entries of a Rego file: those of its
+// METADATA blocks, or a single entry named after its package (or file name)
+// when it has none.
+func regoPolicyEntries(src, fileName string) []PolicyCatalogEntry {
+	if found := parseRegoMetadata(src); len(found) > 0 {
+		return found
+	}
+	pkg := fileName
+	for _, line := range strings.Split(src, "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "package" {
+			pkg = fields[1]
+			break
+		}
+	}
+	return []PolicyCatalogEntry{{ID: pkg, Name: pkg, Frameworks: []string{}}}
+}
+
+// parseRegoMetadata extracts catalog entries from # METADATA comment
+// blocks. Only the flat YAML used by annotations is understood: scalar
+// keys, a nested custom: map, and lists in [a, b] or "- a" form.
+func parseRegoMetadata(src string) []PolicyCatalogEntry {
+	entries := []PolicyCatalogEntry{}
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "# METADATA" {
+			continue
+		}
+		values := map[string]string{}
+		lists := map[string][]string{}
+		lastKey := ""
+		for i++; i < len(lines); i++ {
+			trimmed := strings.TrimSpace(lines[i])
+			if !strings.HasPrefix(trimmed, "#") {
+				break
+			}
+			text := strings.TrimPrefix(strings.TrimPrefix(trimmed, "#"), " ")
+			item := strings.TrimSpace(text)
+			if strings.HasPrefix(item, "- ") && lastKey != "" {
+				lists[lastKey] = append(lists[lastKey], strings.Trim(strings.TrimSpace(item[2:]), `"'`))
+				continue
+			}
+			key, value, ok := strings.Cut(item, ":")
+			if !ok {
+				continue
+			}
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			lastKey = key
+			if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+				for _, v := range strings.Split(strings.Trim(value, "[]"), ",") {
+					if v = strings.Trim(strings.TrimSpace(v), `"'`); v != "" {
+						lists[key] = append(lists[key], v)
+					}
+				}
+				continue
+			}
+			values[key] = strings.Trim(value, `"'`)
+		}
+		i-- // let the outer loop see the line that ended the block
+
+		e := PolicyCatalogEntry{
+			ID: values["id"], Name: values["title"], Category: values["category"],
+			Severity: strings.ToLower(values["severity"]), Service: values["service"],
+			Description: values["description"], Remediation: values["remediation"],
+			Frameworks: lists["frameworks"],
+		}
+		if e.ID == "" {
+			e.ID = e.Name
+		}
+		if e.ID == "" {
+			continue
+		}
+		if e.Name == "" {
+			e.Name = e.ID
+		}
+		if e.Frameworks == nil {
+			e.Frameworks = []string{}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// policyCatalog returns the CLI's policies followed by managed custom
+// policies. If the CLI cannot list its policies, the custom policies are
+// returned with the error, so callers can decide whether a partial catalog
+// will do.
+func policyCatalog() ([]PolicyCatalogEntry, error) {
+	custom, err := customPolicyEntries()
+	if err != nil {
+		log.Printf("Failed to read custom policies: %v", err)
+	}
+	embedded, err := cliPolicies()
+	if err != nil {
+		return custom, err
+	}
+	return append(embedded, custom...), nil
+}
 
+// GET /api/policies — Policy catalog: embedded CLI policies plus managed
+// custom policies. Filter with ?service=, ?category=, ?framework=, ?source=.
+// Without a CLI that lists its policies only ?source=custom can be served.
+func handlePolicyCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	catalog, err := policyCatalog()
+	if err != nil && q.Get("source") != "custom" {
+		jsonError(w, "Policy catalog unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	frameworks := map[string]string{}
+	for _, fw := range complianceFrameworks {
+		frameworks[fw.Key] = fw.Label
+	}
+	out := []PolicyCatalogEntry{}
+	for _, p := range catalog {
+		if s := q.Get("service"); s != "" && !strings.EqualFold(p.Service, s) {
+			continue
+		}
+		if c := q.Get("category"); c != "" && !strings.EqualFold(p.Category, c) {
+			continue
+		}
+		if f := q.Get("framework"); f != "" && !slices.Contains(p.Frameworks, f) {
+			continue
+		}
+		if s := q.Get("source"); s != "" && p.Source != s {
+			continue
+		}
+		out = append(out, p)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"available":   available,
-		"version":     version,
-		"tf_files":    tfFiles,
-		"has_files":   len(tfFiles) > 0,
-		"initialized": initialized,
-		"tf_dir":      "terraform/",
+		"policies":   out,
+		"count":      len(out),
+		"frameworks": frameworks,
 	})
 }
 
-// POST /api/terraform/upload — Upload .tf and .tfvars files.
-func handleTerraformUpload(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// ---------------------------------------------------------------------------
+// Custom policy management
+// ---------------------------------------------------------------------------
+
+// managedPolicyDir holds custom Rego policies, one subdirectory per team.
+// It is relative to the work directory so it can be passed as policy_dir.
+const managedPolicyDir = "policies"
+
+func opaPath() string {
+	if p := os.Getenv("OPA_PATH"); p != "" {
+		return p
 	}
+	return "opa"
+}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 50*1024*1024)
-	if err := r.ParseMultipartForm(50 << 20); err != nil {
-		jsonError(w, "File too large or invalid form", http.StatusBadRequest)
-		return
+// PolicyFile describes a managed .rego file.
+type PolicyFile struct {
+	Team     string    `json:"team"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// PolicyCheckError is one problem found by the syntax check.
+type PolicyCheckError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// validPolicyName reports whether s is a safe team or file name: letters,
+// digits, '-', '_' and '.', not starting with a dot.
+func validPolicyName(s string) bool {
+	if s == "" || s[0] == '.' || len(s) > 100 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
 	}
+	return true
+}
 
-	tfDir := filepath.Join(workDir(), "terraform")
-	os.MkdirAll(tfDir, 0755)
+// teamPolicyDir returns the relative and absolute path of a team's policy
+// directory.
+func teamPolicyDir(team string) (string, string, error) {
+	if !validPolicyName(team) {
+		return "", "", fmt.Errorf("invalid team name: %q", team)
+	}
+	rel := filepath.Join(managedPolicyDir, team)
+	full, err := safePath(rel)
+	return rel, full, err
+}
 
-	uploaded := []string{}
-	for _, fileHeaders := range r.MultipartForm.File {
-		for _, fh := range fileHeaders {
-			if !strings.HasSuffix(fh.Filename, ".tf") && !strings.HasSuffix(fh.Filename, ".tfvars") {
-				jsonError(w, "Only .tf and .tfvars files allowed: "+fh.Filename, http.StatusBadRequest)
-				return
+// policyFilePath resolves a managed policy file, adding the .rego extension
+// if it is missing.
+func policyFilePath(team, name string) (string, string, error) {
+	rel, dir, err := teamPolicyDir(team)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.HasSuffix(name, ".rego") {
+		name += ".rego"
+	}
+	if !validPolicyName(name) {
+		return "", "", fmt.Errorf("invalid policy file name: %q", name)
+	}
+	return filepath.Join(rel, name), filepath.Join(dir, name), nil
+}
+
+func listPolicyFiles(team string) ([]PolicyFile, error) {
+	root, err := safePath(managedPolicyDir)
+	if err != nil {
+		return nil, err
+	}
+	files := []PolicyFile{}
+	teams, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		if !t.IsDir() || (team != "" && t.Name() != team) {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(root, t.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || e.IsDir() || !strings.HasSuffix(e.Name(), ".rego") {
+				continue
 			}
-			safeName := filepath.Base(fh.Filename)
-			file, err := fh.Open()
-			if err != nil {
-				jsonError(w, "Failed to read file: "+err.Error(), http.StatusInternalServerError)
-				return
+			files = append(files, PolicyFile{
+				Team: t.Name(), Name: e.Name(),
+				Path: filepath.Join(managedPolicyDir, t.Name(), e.Name()),
+				Size: info.Size(), Modified: info.ModTime().UTC(),
+			})
+		}
+	}
+	return files, nil
+}
+
+// errOPAUnavailable is returned by checkPolicy when the opa binary cannot be
+// found.
+var errOPAUnavailable = errors.New("opa not available")
+
+// checkPolicy syntax-checks a policy file together with the other files in
+// its team directory, so references between files resolve. It runs
+// `opa check` and returns errOPAUnavailable when OPA is not installed, so
+// unchecked policies are never saved. The checker used is returned with the
+// errors.
+func checkPolicy(team, name string, content []byte) ([]PolicyCheckError, string, error) {
+	if _, err := exec.LookPath(opaPath()); err != nil {
+		return nil, "", errOPAUnavailable
+	}
+
+	tmp, err := os.MkdirTemp("", "cloudrift-policy-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(tmp)
+	if _, dir, err := teamPolicyDir(team); err == nil {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if e.IsDir() || e.Name() == name || !strings.HasSuffix(e.Name(), ".rego") {
+				continue
 			}
-			data, readErr := io.ReadAll(file)
-			file.Close()
-			if readErr != nil {
-				jsonError(w, "Failed to read file data: "+readErr.Error(), http.StatusInternalServerError)
-				return
+			if data, err := os.ReadFile(filepath.Join(dir, e.Name())); err == nil {
+				os.WriteFile(filepath.Join(tmp, e.Name()), data, 0644)
 			}
-			if err := os.WriteFile(filepath.Join(tfDir, safeName), data, 0644); err != nil {
-				jsonError(w, "Failed to save file: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmp, name), content, 0644); err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opaPath(), "check", "--format", "json", tmp)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err == nil {
+		return nil, "opa", nil
+	} else if ctx.Err() != nil {
+		return nil, "opa", fmt.Errorf("opa check timed out")
+	}
+	var result struct {
+		Errors []struct {
+			Code     string `json:"code"`
+			Message  string `json:"message"`
+			Location *struct {
+				File string `json:"file"`
+				Row  int    `json:"row"`
+				Col  int    `json:"col"`
+			} `json:"location"`
+		} `json:"errors"`
+	}
+	out := stdout.Bytes()
+	if len(bytes.TrimSpace(out)) == 0 {
+		out = stderr.Bytes()
+	}
+	if err := json.Unmarshal(out, &result); err != nil || len(result.Errors) == 0 {
+		return nil, "opa", fmt.Errorf("opa check failed: %s", strings.TrimSpace(stderr.String()+stdout.String()))
+	}
+	errs := []PolicyCheckError{}
+	for _, e := range result.Errors {
+		pe := PolicyCheckError{File: name, Code: e.Code, Message: e.Message}
+		if e.Location != nil {
+			pe.File = filepath.Base(e.Location.File)
+			pe.Line, pe.Column = e.Location.Row, e.Location.Col
+		}
+		errs = append(errs, pe)
+	}
+	return errs, "opa", nil
+}
+
+// writePolicy checks a policy and saves it if it has no errors.
+func writePolicy(w http.ResponseWriter, team, name string, content []byte, status int) {
+	relPath, fullPath, err := policyFilePath(team, name)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name = filepath.Base(fullPath)
+	errs, checker, err := checkPolicy(team, name, content)
+	if errors.Is(err, errOPAUnavailable) {
+		jsonError(w, "OPA is not installed; set OPA_PATH to check and save policies", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		jsonError(w, "Policy check failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   fmt.Sprintf("Policy has %d error(s)", len(errs)),
+			"checker": checker,
+			"errors":  errs,
+		})
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		jsonError(w, "Failed to create policy directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(fullPath, content, 0644); err != nil {
+		jsonError(w, "Failed to save policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "ok",
+		"team":       team,
+		"name":       name,
+		"path":       relPath,
+		"policy_dir": filepath.Dir(relPath),
+		"checker":    checker,
+	})
+}
+
+// GET/POST /api/policies/custom — List managed policies or upload one
+// (multipart form with "team" and "file").
+func handleCustomPolicies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		team := r.URL.Query().Get("team")
+		files, err := listPolicyFiles(team)
+		if err != nil {
+			jsonError(w, "Failed to list policies: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"root": managedPolicyDir, "policies": files})
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, 1024*1024)
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			jsonError(w, "File too large or invalid form", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			jsonError(w, "Missing file field: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if !strings.HasSuffix(header.Filename, ".rego") {
+			jsonError(w, "Only .rego files are allowed", http.StatusBadRequest)
+			return
+		}
+		content, err := io.ReadAll(file)
+		if err != nil {
+			jsonError(w, "Failed to read file: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writePolicy(w, r.FormValue("team"), filepath.Base(header.Filename), content, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET/PUT/DELETE /api/policies/custom/{team}/{name} — Read, replace or
+// remove a managed policy. GET returns and PUT takes the raw Rego source.
+func handleCustomPolicy(w http.ResponseWriter, r *http.Request) {
+	team, name := r.PathValue("team"), r.PathValue("name")
+	_, fullPath, err := policyFilePath(team, name)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err := os.ReadFile(fullPath)
+		if err != nil {
+			jsonError(w, "Policy not found: "+team+"/"+name, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+	case http.MethodPut:
+		status := http.StatusOK
+		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+			status = http.StatusCreated
+		}
+		content, err := io.ReadAll(io.LimitReader(r.Body, 1024*1024))
+		if err != nil {
+			jsonError(w, "Failed to read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writePolicy(w, team, name, content, status)
+	case http.MethodDelete:
+		if err := os.Remove(fullPath); err != nil {
+			if os.IsNotExist(err) {
+				jsonError(w, "Policy not found: "+team+"/"+name, http.StatusNotFound)
 				return
 			}
-			uploaded = append(uploaded, safeName)
+			jsonError(w, "Failed to delete policy: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Drop the team directory once its last policy is gone
+		os.Remove(filepath.Dir(fullPath))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PolicyTestResult is the outcome of one Rego test rule.
+type PolicyTestResult struct {
+	Package    string  `json:"package"`
+	Name       string  `json:"name"`
+	File       string  `json:"file"`
+	Line       int     `json:"line"`
+	Status     string  `json:"status"` // "pass", "fail", "error" or "skip"
+	Message    string  `json:"message,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// PolicyCoverage is the test coverage of one policy file.
+type PolicyCoverage struct {
+	File         string  `json:"file"`
+	Coverage     float64 `json:"coverage"`
+	CoveredLines int     `json:"covered_lines"`
+	NotCovered   []int   `json:"not_covered_lines"`
+}
+
+// PolicySample is an example plan made available to tests as data.
+type PolicySample struct {
+	Path     string `json:"path"`
+	DataPath string `json:"data_path"`
+}
+
+// sampleDataName turns a plan file name into a Rego-friendly identifier:
+// terraform-plan.json becomes terraform_plan.
+func sampleDataName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' {
+			return c
+		}
+		return '_'
+	}, name)
+}
+
+// listExamplePlans returns the .json files in examples/, relative to the
+// work directory.
+func listExamplePlans() ([]string, error) {
+	dir, err := safePath("examples")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	plans := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			plans = append(plans, filepath.Join("examples", e.Name()))
 		}
 	}
+	return plans, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":   "ok",
-		"uploaded": uploaded,
-		"tf_dir":   "terraform/",
-	})
+// runOPATest runs `opa test` with extra arguments over dir and decodes the
+// JSON output into v. opa exits non-zero when tests fail, so the exit status
+// is ignored as long as the output parses. A run is stopped after two
+// minutes.
+func runOPATest(dir string, v interface{}, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opaPath(), append(append([]string{"test", "--format", "json"}, args...), dir)...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("opa test timed out after 2m")
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		if runErr != nil {
+			return fmt.Errorf("opa test failed: %s", strings.TrimSpace(stderr.String()+stdout.String()))
+		}
+		return fmt.Errorf("unexpected opa test output: %v", err)
+	}
+	return nil
 }
 
-// POST /api/terraform/plan — Start async terraform plan generation.
-func handleTerraformPlan(w http.ResponseWriter, r *http.Request) {
+// POST /api/policies/test — Run the Rego tests of a managed policy
+// directory with sample plans from examples/ loaded as data.samples.<name>.
+func handlePolicyTest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	if !tfMutex.TryLock() {
-		jsonError(w, "Another Terraform operation is already running", http.StatusConflict)
+	var req struct {
+		Team     string   `json:"team"`
+		Samples  []string `json:"samples"`
+		Coverage *bool    `json:"coverage"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	relDir, dir, err := teamPolicyDir(req.Team)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	files, err := listPolicyFiles(req.Team)
+	if err != nil {
+		jsonError(w, "Failed to list policies: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !slices.ContainsFunc(files, func(f PolicyFile) bool { return strings.HasSuffix(f.Name, "_test.rego") }) {
+		jsonError(w, "No *_test.rego files in "+relDir, http.StatusNotFound)
+		return
+	}
+	if _, err := exec.LookPath(opaPath()); err != nil {
+		jsonError(w, "OPA is not installed; set OPA_PATH to run policy tests", http.StatusServiceUnavailable)
+		return
+	}
+	if len(req.Samples) == 0 {
+		req.Samples, _ = listExamplePlans()
+	}
 
-	tfDir := filepath.Join(workDir(), "terraform")
-	entries, err := os.ReadDir(tfDir)
+	tmp, err := os.MkdirTemp("", "cloudrift-policy-test-")
 	if err != nil {
-		tfMutex.Unlock()
-		jsonError(w, "Terraform directory not found. Upload .tf files first.", http.StatusBadRequest)
+		jsonError(w, "Failed to create temp dir: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	hasTf := false
-	for _, e := range entries {
-		if strings.HasSuffix(e.Name(), ".tf") {
-			hasTf = true
-			break
+	defer os.RemoveAll(tmp)
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.Name))
+		if err == nil {
+			err = os.WriteFile(filepath.Join(tmp, f.Name), data, 0644)
+		}
+		if err != nil {
+			jsonError(w, "Failed to copy policy: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 	}
-	if !hasTf {
-		tfMutex.Unlock()
-		jsonError(w, "No .tf files found. Upload Terraform files first.", http.StatusBadRequest)
-		return
+
+	// OPA loads data.json files under the document path of their directory
+	samples := []PolicySample{}
+	for _, p := range req.Samples {
+		cleaned := filepath.Clean(p)
+		if !strings.HasPrefix(cleaned, "examples"+string(filepath.Separator)) || !strings.HasSuffix(cleaned, ".json") {
+			jsonError(w, "Samples must be .json files under examples/: "+p, http.StatusBadRequest)
+			return
+		}
+		full, err := safePath(cleaned)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			jsonError(w, "Sample not found: "+p, http.StatusNotFound)
+			return
+		}
+		if !json.Valid(data) {
+			jsonError(w, "Sample is not valid JSON: "+p, http.StatusBadRequest)
+			return
+		}
+		name := sampleDataName(cleaned)
+		if i := slices.IndexFunc(samples, func(s PolicySample) bool { return s.DataPath == "data.samples."+name }); i >= 0 {
+			if samples[i].Path == cleaned {
+				continue
+			}
+			jsonError(w, fmt.Sprintf("Samples %s and %s both load as data.samples.%s; rename one", samples[i].Path, cleaned, name), http.StatusBadRequest)
+			return
+		}
+		sampleDir := filepath.Join(tmp, "samples", name)
+		os.MkdirAll(sampleDir, 0755)
+		if err := os.WriteFile(filepath.Join(sampleDir, "data.json"), data, 0644); err != nil {
+			jsonError(w, "Failed to stage sample: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		samples = append(samples, PolicySample{Path: cleaned, DataPath: "data.samples." + name})
 	}
 
-	jobID := fmt.Sprintf("tf-%d", time.Now().UnixMilli())
-	job := &TerraformJob{
-		ID:        jobID,
-		Status:    "pending",
-		Phase:     "Starting...",
-		StartedAt: time.Now(),
+	var raw []struct {
+		Location struct {
+			File string `json:"file"`
+			Row  int    `json:"row"`
+		} `json:"location"`
+		Package string `json:"package"`
+		Name    string `json:"name"`
+		Fail    bool   `json:"fail"`
+		Skip    bool   `json:"skip"`
+		Error   *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		Duration int64  `json:"duration"`
+		Output   []byte `json:"output"`
+	}
+	if err := runOPATest(tmp, &raw); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	tfJobsMu.Lock()
-	tfJobs[jobID] = job
-	tfJobsMu.Unlock()
+	summary := map[string]int{"total": len(raw), "pass": 0, "fail": 0, "error": 0, "skip": 0}
+	tests := []PolicyTestResult{}
+	for _, t := range raw {
+		res := PolicyTestResult{
+			Package: strings.TrimPrefix(t.Package, "data."), Name: t.Name,
+			File: filepath.Base(t.Location.File), Line: t.Location.Row,
+			Status: "pass", DurationMs: float64(t.Duration) / 1e6,
+		}
+		output := strings.TrimSpace(string(t.Output))
+		switch {
+		case t.Skip:
+			res.Status = "skip"
+		case t.Error != nil:
+			res.Status, res.Message = "error", t.Error.Message
+		case t.Fail:
+			res.Status, res.Message = "fail", "test rule evaluated to false or undefined"
+			if output != "" {
+				res.Message += ": " + output
+			}
+		}
+		summary[res.Status]++
+		tests = append(tests, res)
+	}
 
-	go runTerraformPipeline(job, tfDir)
+	response := map[string]interface{}{
+		"team":       req.Team,
+		"policy_dir": relDir,
+		"samples":    samples,
+		"summary":    summary,
+		"tests":      tests,
+	}
+	if req.Coverage == nil || *req.Coverage {
+		var cov struct {
+			Files map[string]struct {
+				Covered []struct {
+					Start struct{ Row int } `json:"start"`
+					End   struct{ Row int } `json:"end"`
+				} `json:"covered"`
+				NotCovered []struct {
+					Start struct{ Row int } `json:"start"`
+					End   struct{ Row int } `json:"end"`
+				} `json:"not_covered"`
+				Coverage float64 `json:"coverage"`
+			} `json:"files"`
+			Coverage float64 `json:"coverage"`
+		}
+		if err := runOPATest(tmp, &cov, "--coverage"); err != nil {
+			log.Printf("Policy coverage for %s failed: %v", relDir, err)
+		} else {
+			files := []PolicyCoverage{}
+			for path, f := range cov.Files {
+				if strings.HasSuffix(path, "_test.rego") {
+					continue
+				}
+				pc := PolicyCoverage{File: filepath.Base(path), Coverage: math.Round(f.Coverage*10) / 10, NotCovered: []int{}}
+				for _, c := range f.Covered {
+					pc.CoveredLines += c.End.Row - c.Start.Row + 1
+				}
+				for _, c := range f.NotCovered {
+					for row := c.Start.Row; row <= c.End.Row; row++ {
+						pc.NotCovered = append(pc.NotCovered, row)
+					}
+				}
+				files = append(files, pc)
+			}
+			sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+			response["coverage"] = map[string]interface{}{
+				"overall": math.Round(cov.Coverage*10) / 10,
+				"files":   files,
+			}
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "started",
-		"job_id": jobID,
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
-func runTerraformPipeline(job *TerraformJob, tfDir string) {
-	defer tfMutex.Unlock()
+// ---------------------------------------------------------------------------
+// Minimal PDF writer
+// ---------------------------------------------------------------------------
 
-	tf := terraformPath()
-	var outputBuf strings.Builder
+const (
+	pdfPageWidth  = 595.28 // A4
+	pdfPageHeight = 841.89
+	pdfMargin     = 48.0
+)
 
-	// Step 1: terraform init (10 min timeout — downloads providers)
-	job.Status = "init"
-	job.Phase = "Running terraform init..."
-	initCtx, initCancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer initCancel()
-	initCmd := exec.CommandContext(initCtx, tf, "init", "-no-color", "-input=false")
-	initCmd.Dir = tfDir
-	initOutput, initErr := initCmd.CombinedOutput()
-	outputBuf.Write(initOutput)
-	outputBuf.WriteByte('\n')
-	job.Output = outputBuf.String()
-	if initErr != nil {
-		job.Status = "error"
-		job.Error = "terraform init failed: " + initErr.Error()
-		job.Phase = "Init failed"
-		job.DoneAt = time.Now()
-		return
-	}
+// pdfWriter produces simple text-and-table PDF documents. It only uses the
+// built-in Helvetica fonts, which every PDF viewer provides.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	y     float64
+}
 
-	// Step 2: terraform plan (10 min timeout)
-	job.Status = "plan"
-	job.Phase = "Running terraform plan..."
-	planBinaryPath := filepath.Join(tfDir, "tfplan.binary")
-	planCtx, planCancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer planCancel()
-	planCmd := exec.CommandContext(planCtx, tf, "plan", "-out="+planBinaryPath, "-no-color", "-input=false")
-	planCmd.Dir = tfDir
-	planOutput, planErr := planCmd.CombinedOutput()
-	outputBuf.Write(planOutput)
-	outputBuf.WriteByte('\n')
-	job.Output = outputBuf.String()
-	if planErr != nil {
-		job.Status = "error"
-		job.Error = "terraform plan failed: " + planErr.Error()
-		job.Phase = "Plan failed"
-		job.DoneAt = time.Now()
-		return
-	}
+func newPDFWriter() *pdfWriter {
+	p := &pdfWriter{}
+	p.newPage()
+	return p
+}
 
-	// Step 3: terraform show -json (5 min timeout)
-	job.Status = "show"
-	job.Phase = "Converting plan to JSON..."
-	showCtx, showCancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer showCancel()
-	showCmd := exec.CommandContext(showCtx, tf, "show", "-json", "-no-color", planBinaryPath)
-	showCmd.Dir = tfDir
-	showOutput, showErr := showCmd.CombinedOutput()
-	if showErr != nil {
-		job.Status = "error"
-		job.Error = "terraform show failed: " + showErr.Error()
-		job.Phase = "Show failed"
-		job.DoneAt = time.Now()
-		return
-	}
+func (p *pdfWriter) newPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pdfPageHeight - pdfMargin
+}
 
-	if !json.Valid(showOutput) {
-		job.Status = "error"
-		job.Error = "terraform show produced invalid JSON"
-		job.Phase = "Invalid output"
-		job.DoneAt = time.Now()
-		return
+func (p *pdfWriter) page() *bytes.Buffer {
+	return p.pages[len(p.pages)-1]
+}
+
+// ensure starts a new page if fewer than h points remain.
+func (p *pdfWriter) ensure(h float64) {
+	if p.y-h < pdfMargin {
+		p.newPage()
 	}
+}
 
-	// Step 4: Save plan.json
-	planJsonPath := "examples/terraform-plan.json"
-	fullPlanPath, pathErr := safePath(planJsonPath)
-	if pathErr != nil {
-		job.Status = "error"
-		job.Error = "Path error: " + pathErr.Error()
-		job.DoneAt = time.Now()
-		return
+func (p *pdfWriter) space(h float64) {
+	p.y -= h
+}
+
+func (p *pdfWriter) drawText(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
 	}
-	if err := os.WriteFile(fullPlanPath, showOutput, 0644); err != nil {
-		job.Status = "error"
-		job.Error = "Failed to save plan JSON: " + err.Error()
-		job.DoneAt = time.Now()
-		return
+	fmt.Fprintf(p.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(s))
+}
+
+func (p *pdfWriter) textLine(s string, size float64, bold bool) {
+	p.ensure(size * 1.4)
+	p.y -= size * 1.2
+	p.drawText(pdfMargin, p.y, size, bold, s)
+	p.y -= size * 0.3
+}
+
+func (p *pdfWriter) heading(s string) {
+	p.ensure(60)
+	p.space(14)
+	p.textLine(s, 14, true)
+	fmt.Fprintf(p.page(), "0.25 0.32 0.71 RG 1 w %.2f %.2f m %.2f %.2f l S 0 G\n",
+		pdfMargin, p.y, pdfPageWidth-pdfMargin, p.y)
+	p.space(8)
+}
+
+func (p *pdfWriter) subheading(s string) {
+	p.ensure(40)
+	p.space(6)
+	p.textLine(s, 11, true)
+	p.space(2)
+}
+
+func (p *pdfWriter) paragraph(s string, size float64, bold bool) {
+	for _, line := range pdfWrap(s, pdfPageWidth-2*pdfMargin, size) {
+		p.textLine(line, size, bold)
 	}
+}
 
-	// Step 5: Update config
-	configFile := "config/cloudrift-s3.yml"
-	fullConfigPath, _ := safePath(configFile)
-	if configData, readErr := os.ReadFile(fullConfigPath); readErr == nil {
-		lines := strings.Split(string(configData), "\n")
-		updated := false
-		for i, line := range lines {
-			if strings.HasPrefix(strings.TrimSpace(line), "plan_path:") {
-				lines[i] = "plan_path: ./examples/terraform-plan.json"
-				updated = true
-				break
+// table draws a grid with a shaded header row. Cell text is wrapped to the
+// column width and rows split across pages when needed.
+func (p *pdfWriter) table(widths []float64, header []string, rows [][]string) {
+	const size, pad = 8.0, 3.0
+	lineHeight := size * 1.25
+
+	drawRow := func(cells []string, bold bool) {
+		wrapped := make([][]string, len(widths))
+		lines := 1
+		for i := range widths {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			wrapped[i] = pdfWrap(cell, widths[i]-2*pad, size)
+			if len(wrapped[i]) > lines {
+				lines = len(wrapped[i])
 			}
 		}
-		if !updated {
-			lines = append(lines, "plan_path: ./examples/terraform-plan.json")
+		height := float64(lines)*lineHeight + 2*pad
+		p.ensure(height)
+		top := p.y
+		x := pdfMargin
+		buf := p.page()
+		if bold {
+			fmt.Fprintf(buf, "0.93 0.94 0.96 rg %.2f %.2f %.2f %.2f re f 0 g\n", x, top-height, sum(widths), height)
 		}
-		os.WriteFile(fullConfigPath, []byte(strings.Join(lines, "\n")), 0644)
+		for i, w := range widths {
+			fmt.Fprintf(buf, "0.8 G 0.5 w %.2f %.2f %.2f %.2f re S 0 G\n", x, top-height, w, height)
+			for j, line := range wrapped[i] {
+				p.drawText(x+pad, top-pad-float64(j+1)*lineHeight+2, size, bold, line)
+			}
+			x += w
+		}
+		p.y = top - height
 	}
 
-	job.Status = "completed"
-	job.Phase = "Plan generated successfully"
-	job.PlanPath = planJsonPath
-	job.DoneAt = time.Now()
-
-	// Clean up binary plan file
-	os.Remove(planBinaryPath)
+	p.ensure(3 * lineHeight)
+	drawRow(header, true)
+	for _, row := range rows {
+		before := len(p.pages)
+		drawRow(row, false)
+		if len(p.pages) != before {
+			// Row moved to a new page; the header was left behind, so the
+			// row is redrawn after a fresh header.
+			p.pages[len(p.pages)-1].Reset()
+			p.y = pdfPageHeight - pdfMargin
+			drawRow(header, true)
+			drawRow(row, false)
+		}
+	}
+	p.space(8)
 }
 
-// GET /api/terraform/job?id=<job_id> — Poll job status.
-func handleTerraformJob(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
 	}
+	return total
+}
 
-	jobID := r.URL.Query().Get("id")
-	if jobID == "" {
-		jsonError(w, "job id is required", http.StatusBadRequest)
-		return
+// pdfWrap splits text into lines no wider than width, using an average
+// Helvetica glyph width. Explicit newlines are kept.
+func pdfWrap(s string, width, size float64) []string {
+	maxChars := int(width / (size * 0.52))
+	if maxChars < 1 {
+		maxChars = 1
 	}
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			for len([]rune(word)) > maxChars {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				r := []rune(word)
+				lines = append(lines, string(r[:maxChars]))
+				word = string(r[maxChars:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= maxChars:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
 
-	tfJobsMu.Lock()
-	job, exists := tfJobs[jobID]
-	tfJobsMu.Unlock()
+// pdfEscape encodes a string as the body of a PDF literal string in
+// WinAnsiEncoding. Characters outside Latin-1 are replaced.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '—' || r == '–':
+			b.WriteByte('-')
+		case r == '→':
+			b.WriteString("->")
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
 
-	if !exists {
-		jsonError(w, "Job not found: "+jobID, http.StatusNotFound)
-		return
+// bytes assembles the pages into a complete PDF file.
+func (p *pdfWriter) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
 	}
 
-	elapsed := time.Since(job.StartedAt).Seconds()
-	if !job.DoneAt.IsZero() {
-		elapsed = job.DoneAt.Sub(job.StartedAt).Seconds()
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-4 are fixed; each page then takes a page and a content object.
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range p.pages {
+		footer := fmt.Sprintf("BT /F1 8 Tf %.2f %.2f Td (Page %d of %d) Tj ET\n",
+			pdfPageWidth-pdfMargin-50, pdfMargin/2, i+1, len(p.pages))
+		content := page.String() + footer
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":        job.ID,
-		"status":    job.Status,
-		"phase":     job.Phase,
-		"error":     job.Error,
-		"plan_path": job.PlanPath,
-		"elapsed_s": int(elapsed),
-		"output":    job.Output,
-	})
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
 }
 
 // ---------------------------------------------------------------------------